@@ -12,12 +12,13 @@ import (
 // SchemaAuditLog representa una fila en la tabla de auditoría
 type SchemaAuditLog struct {
 	ID       uint      `gorm:"primaryKey" json:"id"`
-	Version  string    `json:"version"`                         // Versión de la migración o seed ejecutado
-	Commit   string    `json:"commit"`                          // Hash del commit de Git
-	Action   string    `json:"action"`                          // Tipo de acción: apply, rollback, seed, etc.
-	User     string    `json:"user"`                            // Usuario del sistema
-	Host     string    `json:"host"`                            // Nombre del host
-	LoggedAt time.Time `gorm:"autoCreateTime" json:"logged_at"` // Timestamp generado automáticamente
+	Version  string    `json:"version"`                           // Versión de la migración o seed ejecutado
+	Commit   string    `json:"commit"`                            // Hash del commit de Git
+	Action   string    `json:"action"`                            // Tipo de acción: apply, rollback, seed, etc.
+	User     string    `json:"user"`                              // Usuario del sistema
+	Host     string    `json:"host"`                              // Nombre del host
+	Detail   string    `gorm:"type:text" json:"detail,omitempty"` // Motivo de cambios manuales del historial
+	LoggedAt time.Time `gorm:"autoCreateTime" json:"logged_at"`   // Timestamp generado automáticamente
 }
 
 // TableName define el nombre de la tabla usada por GORM
@@ -25,10 +26,22 @@ func (SchemaAuditLog) TableName() string {
 	return "schema_audit_log"
 }
 
-// EnsureAuditTable asegura que la tabla schema_audit_log exista.
-// Usa AutoMigrate para crearla si no está.
+// EnsureAuditTable asegura que la tabla de auditoría (schema_audit_log o la
+// configurada con SetMetaTables) exista. Usa AutoMigrate para crearla si no está.
 func EnsureAuditTable(db *gorm.DB) error {
-	return db.AutoMigrate(&SchemaAuditLog{})
+	return db.Table(packageTables().auditTable()).AutoMigrate(&SchemaAuditLog{})
+}
+
+// ensureAuditTables creates the audit table, and the field history table when
+// fieldHistory is set, reporting failures to the run's observer: a missing
+// audit table must not block a migration.
+func ensureAuditTables(db *gorm.DB, opts MigrateOptions, fieldHistory bool) {
+	obs := opts.observer()
+	t := opts.tables()
+	reportAuditError(obs, "", "create "+t.auditTable(), db.Table(t.auditTable()).AutoMigrate(&SchemaAuditLog{}))
+	if fieldHistory {
+		reportAuditError(obs, "", "create "+t.fieldHistoryTable(), db.Table(t.fieldHistoryTable()).AutoMigrate(&FieldHistory{}))
+	}
 }
 
 func gitCommitHash() string {
@@ -40,8 +53,27 @@ func gitCommitHash() string {
 }
 
 // LogAuditEvent inserta una nueva entrada de auditoría.
-// Detecta automáticamente el usuario y el hostname del sistema.
+// Detecta automáticamente el usuario y el hostname del sistema. Si la escritura
+// falla, se reporta un EventAuditWriteFailed al observer del paquete.
 func LogAuditEvent(db *gorm.DB, version string, action string) {
+	reportAuditError(ObserverFunc(notify), version, action, writeAuditEvent(db, packageTables(), version, action))
+}
+
+// reportAuditError reports a failed audit write to obs. Audit failures never
+// fail the operation being audited.
+func reportAuditError(obs Observer, version, action string, err error) {
+	if err != nil {
+		obs.OnEvent(Event{Kind: EventAuditWriteFailed, Version: version, Message: action, Err: err})
+	}
+}
+
+func writeAuditEvent(db *gorm.DB, t MetaTables, version string, action string) error {
+	return writeAuditEntry(db, t, SchemaAuditLog{Version: version, Action: action})
+}
+
+// writeAuditEntry completes entry with the commit, user and host and inserts
+// it.
+func writeAuditEntry(db *gorm.DB, t MetaTables, entry SchemaAuditLog) error {
 	user := os.Getenv("USER")
 	if user == "" {
 		if out, err := exec.Command("whoami").Output(); err == nil {
@@ -51,21 +83,16 @@ func LogAuditEvent(db *gorm.DB, version string, action string) {
 	host, _ := os.Hostname()
 	commit := gitCommitHash()
 
-	entry := SchemaAuditLog{
-		Version: version,
-		Commit:  commit,
-		Action:  action,
-		User:    user,
-		Host:    host,
-	}
-
-	_ = db.Create(&entry).Error // Ignoramos el error por simplicidad
+	entry.Commit = commit
+	entry.User = user
+	entry.Host = host
+	return db.Table(t.auditTable()).Create(&entry).Error
 }
 
 // ListAuditLog retorna todas las entradas de auditoría ordenadas por LoggedAt
 func ListAuditLog(db *gorm.DB) ([]SchemaAuditLog, error) {
 	var logs []SchemaAuditLog
-	if err := db.Order("logged_at").Find(&logs).Error; err != nil {
+	if err := db.Table(packageTables().auditTable()).Order("logged_at").Find(&logs).Error; err != nil {
 		return nil, err
 	}
 	return logs, nil
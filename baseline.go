@@ -0,0 +1,171 @@
+package driftflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BaselineOptions controls how Baseline adopts an existing database.
+type BaselineOptions struct {
+	MigrateOptions
+	// VerifySchema compares the live schema with schema.lock.json before
+	// marking anything as applied and fails on any difference.
+	VerifySchema bool
+}
+
+// Baseline marks every migration up to and including version as applied
+// without executing it. Use it to adopt a legacy database whose schema already
+// matches those migrations. The rows share a dedicated batch and carry the
+// real checksums, so later runs validate them like any applied migration.
+func Baseline(db *gorm.DB, dir string, version string) error {
+	return BaselineWithOptions(db, dir, version, BaselineOptions{})
+}
+
+// BaselineWithOptions is Baseline with explicit options.
+func BaselineWithOptions(db *gorm.DB, dir string, version string, opts BaselineOptions) error {
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := checkSource(src, opts.observer()); err != nil {
+		return err
+	}
+	return withMigrationLock(db, opts.MigrateOptions, func(db *gorm.DB) error {
+		if err := ensureMigrationsTable(db, opts.tables()); err != nil {
+			return err
+		}
+		ensureAuditTables(db, opts.MigrateOptions, false)
+		state, err := loadMigrationState(db, src, opts.MigrateOptions)
+		if err != nil {
+			return err
+		}
+		rows, err := state.planBaseline(version)
+		if err != nil {
+			return err
+		}
+		if opts.VerifySchema {
+			snap, err := loadSnapshot(filepath.Join(dir, "schema.lock.json"))
+			if err != nil {
+				return err
+			}
+			live, err := schemaMap(db)
+			if err != nil {
+				return err
+			}
+			if diffs := state.verifyBaselineSchema(version, snap, live); len(diffs) > 0 {
+				return fmt.Errorf("live schema does not match schema.lock.json at %s:\n - %s", version, strings.Join(diffs, "\n - "))
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		run := loadRunInfo(db, opts.observer())
+		for i := range rows {
+			rows[i].DBUser = run.dbUser
+			rows[i].Host = run.host
+			rows[i].DriftflowVersion = run.version
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			return tx.Table(state.tables.historyTable()).Create(&rows).Error
+		}); err != nil {
+			return err
+		}
+		for _, r := range rows {
+			state.audit(db, r.Version, "baseline")
+		}
+		return nil
+	})
+}
+
+// planBaseline returns the history rows Baseline would insert for every
+// not-yet-applied version up to and including target.
+func (s *migrationState) planBaseline(target string) ([]SchemaMigration, error) {
+	if !s.known(target) {
+		return nil, fmt.Errorf("target version not found: %s", target)
+	}
+	now := time.Now().UTC()
+	var rows []SchemaMigration
+	for _, v := range s.versions {
+		if _, ok := s.applied[v]; !ok {
+			checksum, err := s.checksum(v)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, SchemaMigration{
+				Version:         v,
+				Batch:           s.nextBatch,
+				Checksum:        checksum,
+				ChecksumVersion: s.checksumVersion(v),
+				AppliedAt:       now,
+				Status:          HistoryStatusBaseline,
+			})
+		}
+		if v == target {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// verifyBaselineSchema compares the live schema with the snapshot. Only tables
+// whose create migration is at or before target are expected to exist; column
+// sets are compared only when target is the newest migration, since the
+// snapshot reflects the latest model state.
+func (s *migrationState) verifyBaselineSchema(target string, snap *SchemaSnapshot, live schemaInfo) []string {
+	liveTables := make(map[string]tableInfo, len(live))
+	for t, cols := range live {
+		lower := make(tableInfo, len(cols))
+		for c, typ := range cols {
+			lower[strings.ToLower(c)] = typ
+		}
+		liveTables[strings.ToLower(t)] = lower
+	}
+	isLatest := len(s.versions) > 0 && s.versions[len(s.versions)-1] == target
+
+	tables := make([]string, 0, len(snap.Tables))
+	for t := range snap.Tables {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+
+	var diffs []string
+	for _, table := range tables {
+		created := s.createVersion(table)
+		if created == "" || s.before(target, created) {
+			continue
+		}
+		liveCols, ok := liveTables[strings.ToLower(table)]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("missing table %s", table))
+			continue
+		}
+		if !isLatest {
+			continue
+		}
+		cols := make([]string, 0, len(snap.Tables[table].Columns))
+		for c := range snap.Tables[table].Columns {
+			cols = append(cols, c)
+		}
+		sort.Strings(cols)
+		for _, c := range cols {
+			if _, ok := liveCols[strings.ToLower(c)]; !ok {
+				diffs = append(diffs, fmt.Sprintf("missing column %s.%s", table, c))
+			}
+		}
+	}
+	return diffs
+}
+
+// createVersion returns the version of the generated create migration for
+// table, or "" if there is none.
+func (s *migrationState) createVersion(table string) string {
+	suffix := "_create_" + table + "_table"
+	for _, v := range s.versions {
+		if strings.HasSuffix(v, suffix) {
+			return v
+		}
+	}
+	return ""
+}
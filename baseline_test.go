@@ -0,0 +1,42 @@
+package driftflow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanBaseline(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users")
+	rows, err := s.planBaseline("002_posts")
+	if err != nil {
+		t.Fatalf("planBaseline: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Version != "002_posts" || rows[0].Batch != 2 || rows[0].Checksum == "" {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+	if _, err := s.planBaseline("999_missing"); err == nil {
+		t.Fatalf("expected unknown version error")
+	}
+}
+
+func TestVerifyBaselineSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "001_create_users_table", "CREATE TABLE users(id int, email text);", "DROP TABLE users;")
+	writeMigration(t, dir, "002_create_posts_table", "CREATE TABLE posts(id int);", "DROP TABLE posts;")
+	s := newTestState(t, dir)
+	snap := &SchemaSnapshot{Tables: map[string]SnapshotTable{
+		"users": {Columns: map[string]string{"id": "integer", "email": "text"}},
+		"posts": {Columns: map[string]string{"id": "integer"}},
+	}}
+
+	live := schemaInfo{"users": {"id": "int4"}}
+	if diffs := s.verifyBaselineSchema("001_create_users_table", snap, live); len(diffs) != 0 {
+		t.Fatalf("expected no diffs before latest version, got %v", diffs)
+	}
+	got := s.verifyBaselineSchema("002_create_posts_table", snap, live)
+	want := []string{"missing table posts", "missing column users.email"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package driftflow
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// RollbackBatch reverts every migration applied in the last n batches, newest
+// first. A batch is the set of migrations recorded by a single Up or
+// MigrateTo run. If n is less than 1 or greater than the number of batches,
+// all applied migrations are rolled back.
+func RollbackBatch(db *gorm.DB, dir string, n int) error {
+	return RollbackBatchWithOptions(db, dir, n, MigrateOptions{})
+}
+
+// RollbackBatchWithOptions is RollbackBatch with explicit runner options.
+func RollbackBatchWithOptions(db *gorm.DB, dir string, n int, opts MigrateOptions) error {
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := checkSource(src, opts.observer()); err != nil {
+		return err
+	}
+	return withMigrationLock(db, opts, func(db *gorm.DB) error {
+		if err := ensureMigrationsTable(db, opts.tables()); err != nil {
+			return err
+		}
+		ensureAuditTables(db, opts, true)
+		state, err := loadMigrationState(db, src, opts)
+		if err != nil {
+			return err
+		}
+		plan, err := state.planRollbackBatch(n)
+		if err != nil {
+			return err
+		}
+		return state.execute(db, plan)
+	})
+}
+
+// PlanRollbackBatch returns the steps RollbackBatch(n) would execute without
+// running them.
+func PlanRollbackBatch(db *gorm.DB, dir string, n int) ([]PlannedMigration, error) {
+	return PlanRollbackBatchWithOptions(db, dir, n, MigrateOptions{})
+}
+
+// PlanRollbackBatchWithOptions is PlanRollbackBatch with explicit runner
+// options.
+func PlanRollbackBatchWithOptions(db *gorm.DB, dir string, n int, opts MigrateOptions) ([]PlannedMigration, error) {
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := checkSource(src, opts.observer()); err != nil {
+		return nil, err
+	}
+	state, err := loadMigrationState(db, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	return state.planRollbackBatch(n)
+}
+
+func (s *migrationState) planRollbackBatch(n int) ([]PlannedMigration, error) {
+	seen := map[int]bool{}
+	var batches []int
+	for _, m := range s.applied {
+		if !seen[m.Batch] {
+			seen[m.Batch] = true
+			batches = append(batches, m.Batch)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(batches)))
+	if n < 1 || n > len(batches) {
+		n = len(batches)
+	}
+	selected := make(map[int]bool, n)
+	for _, b := range batches[:n] {
+		selected[b] = true
+	}
+
+	var plan []PlannedMigration
+	for i := len(s.appliedOrder) - 1; i >= 0; i-- {
+		version := s.appliedOrder[i]
+		if !selected[s.applied[version].Batch] {
+			continue
+		}
+		step, err := s.downStep(version)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, step)
+	}
+	if err := s.checkDependencies(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
@@ -0,0 +1,34 @@
+package driftflow
+
+import "testing"
+
+func TestPlanRollbackBatch(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users", "002_posts", "003_tags")
+	for _, v := range []string{"002_posts", "003_tags"} {
+		m := s.applied[v]
+		m.Batch = 2
+		s.applied[v] = m
+	}
+
+	plan, err := s.planRollbackBatch(1)
+	if err != nil {
+		t.Fatalf("planRollbackBatch: %v", err)
+	}
+	if len(plan) != 2 || plan[0].Version != "003_tags" || plan[1].Version != "002_posts" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	for _, step := range plan {
+		if step.Direction != DirectionDown || step.Batch != 2 {
+			t.Fatalf("unexpected step: %+v", step)
+		}
+	}
+
+	plan, err = s.planRollbackBatch(0)
+	if err != nil {
+		t.Fatalf("planRollbackBatch: %v", err)
+	}
+	if len(plan) != 3 || plan[2].Version != "001_users" {
+		t.Fatalf("expected all migrations rolled back, got %+v", plan)
+	}
+}
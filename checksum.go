@@ -0,0 +1,314 @@
+package driftflow
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// ChecksumAlgorithm selects how migration checksums are computed. Every
+// history row and manifest entry records the algorithm of its checksum, so
+// switching algorithms keeps older rows and entries valid: they are checked
+// with their own algorithm and rewritten with the current one while their
+// file still matches.
+type ChecksumAlgorithm int
+
+const (
+	// ChecksumRaw hashes the file bytes. Rows and manifest entries written
+	// before checksum algorithms were recorded use it.
+	ChecksumRaw ChecksumAlgorithm = 1
+	// ChecksumNormalized hashes the parsed Up and Down sections (the body of
+	// a repeatable migration) with LF line endings and without trailing
+	// whitespace, so a CRLF checkout or an editor trimming lines does not
+	// change it.
+	ChecksumNormalized ChecksumAlgorithm = 2
+	// ChecksumNormalizedNoComments is ChecksumNormalized without blank lines
+	// and whole-line "--" comments.
+	ChecksumNormalizedNoComments ChecksumAlgorithm = 3
+
+	// DefaultChecksumAlgorithm is used when none is set.
+	DefaultChecksumAlgorithm = ChecksumNormalized
+)
+
+var (
+	checksumMu               sync.RWMutex
+	defaultChecksumAlgorithm ChecksumAlgorithm
+)
+
+// SetChecksumAlgorithm sets the algorithm used when no
+// MigrateOptions.ChecksumAlgorithm is given and for the manifest written by
+// GenerateMigrations. Zero restores DefaultChecksumAlgorithm.
+func SetChecksumAlgorithm(a ChecksumAlgorithm) {
+	checksumMu.Lock()
+	defer checksumMu.Unlock()
+	defaultChecksumAlgorithm = a
+}
+
+// packageChecksumAlgorithm returns the algorithm set with
+// SetChecksumAlgorithm.
+func packageChecksumAlgorithm() ChecksumAlgorithm {
+	checksumMu.RLock()
+	defer checksumMu.RUnlock()
+	return defaultChecksumAlgorithm.resolve()
+}
+
+// checksumAlgorithm returns the algorithm of the run.
+func (o MigrateOptions) checksumAlgorithm() ChecksumAlgorithm {
+	if o.ChecksumAlgorithm != 0 {
+		return o.ChecksumAlgorithm
+	}
+	return packageChecksumAlgorithm()
+}
+
+// resolve maps zero to DefaultChecksumAlgorithm.
+func (a ChecksumAlgorithm) resolve() ChecksumAlgorithm {
+	if a == 0 {
+		return DefaultChecksumAlgorithm
+	}
+	return a
+}
+
+// recorded maps the zero algorithm of a row or manifest entry written before
+// algorithms were recorded to ChecksumRaw.
+func (a ChecksumAlgorithm) recorded() ChecksumAlgorithm {
+	if a == 0 {
+		return ChecksumRaw
+	}
+	return a
+}
+
+// migrationChecksum returns the checksum of a parsed migration file.
+func migrationChecksum(b []byte, script migrationScript, a ChecksumAlgorithm) (string, error) {
+	if a == ChecksumRaw {
+		return sha256Hex(b), nil
+	}
+	if err := a.check(); err != nil {
+		return "", err
+	}
+	strip := a == ChecksumNormalizedNoComments
+	var sb strings.Builder
+	writeChecksumHeader(&sb, script)
+	if script.conditional() {
+		// every section counts, whichever of them run
+		for _, sec := range script.Sections {
+			sb.WriteString(sec.marker())
+			sb.WriteString("\n")
+			sb.WriteString(normalizeChecksumText(sec.SQL, strip))
+			sb.WriteString("\n")
+		}
+		return sha256Hex([]byte(sb.String())), nil
+	}
+	writeChecksumMarker(&sb, migrationUpMarker, script.UpNoTransaction)
+	sb.WriteString(normalizeChecksumText(script.Up, strip))
+	sb.WriteString("\n")
+	writeChecksumMarker(&sb, migrationDownMarker, script.DownNoTransaction)
+	sb.WriteString(normalizeChecksumText(script.Down, strip))
+	sb.WriteString("\n")
+	return sha256Hex([]byte(sb.String())), nil
+}
+
+// repeatableChecksum returns the checksum of a repeatable migration file.
+func repeatableChecksum(b []byte, a ChecksumAlgorithm) (string, error) {
+	if a == ChecksumRaw {
+		return sha256Hex(b), nil
+	}
+	if err := a.check(); err != nil {
+		return "", err
+	}
+	return sha256Hex([]byte(normalizeChecksumText(string(b), a == ChecksumNormalizedNoComments) + "\n")), nil
+}
+
+// fileChecksum returns the checksum of the migration file name: a repeatable
+// migration or a versioned one with Up and Down sections.
+func fileChecksum(name string, b []byte, a ChecksumAlgorithm) (string, error) {
+	if isRepeatableFile(name) {
+		return repeatableChecksum(b, a)
+	}
+	if a == ChecksumRaw {
+		return sha256Hex(b), nil
+	}
+	script, err := parseMigrationScript(string(b))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return migrationChecksum(b, script, a)
+}
+
+func (a ChecksumAlgorithm) check() error {
+	switch a {
+	case ChecksumRaw, ChecksumNormalized, ChecksumNormalizedNoComments:
+		return nil
+	}
+	return fmt.Errorf("unknown checksum algorithm %d", a)
+}
+
+// writeChecksumHeader writes the directives before the Up marker in a
+// canonical form, so editing them after a migration is applied is detected.
+// Files without directives keep the checksum they had before directives were
+// hashed.
+func writeChecksumHeader(sb *strings.Builder, script migrationScript) {
+	if script.Irreversible {
+		sb.WriteString(migrationIrreversibleMarker + "\n")
+	}
+	if len(script.DependsOn) > 0 {
+		sb.WriteString(migrationDependsOnMarker + " " + strings.Join(script.DependsOn, ",") + "\n")
+	}
+	for _, v := range script.Squashes {
+		sb.WriteString(migrationSquashesMarker + " " + v + "\n")
+	}
+}
+
+func writeChecksumMarker(sb *strings.Builder, marker string, noTransaction bool) {
+	sb.WriteString(marker)
+	if noTransaction {
+		sb.WriteString(" notransaction")
+	}
+	sb.WriteString("\n")
+}
+
+// normalizeChecksumText converts line endings to LF, trims trailing
+// whitespace from every line and surrounding blank lines from the text, and
+// with stripComments drops blank lines and whole-line "--" comments.
+func normalizeChecksumText(text string, stripComments bool) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if stripComments {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+				continue
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
+
+// checksumMatches reports whether checksum is the checksum of the file name
+// with contents b under a. A raw checksum also matches b with LF line endings
+// and without trailing whitespace, so a row or manifest entry recorded before
+// a CRLF checkout or an editor trimming lines can still be upgraded.
+func checksumMatches(name string, b []byte, a ChecksumAlgorithm, checksum string) (bool, error) {
+	current, err := fileChecksum(name, b, a)
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(current, checksum) {
+		return true, nil
+	}
+	if a != ChecksumRaw {
+		return false, nil
+	}
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.EqualFold(sha256Hex([]byte(strings.Join(lines, "\n"))), checksum), nil
+}
+
+// upgradeChecksums moves history rows written with another algorithm to the
+// algorithm of the run: a row whose file still matches under its own
+// algorithm gets the current checksum, in memory and in s.upgraded for
+// saveChecksumUpgrades. Rows that no longer match keep their checksum and
+// are reported as modified. Go migrations supply their own checksum.
+func (s *migrationState) upgradeChecksums() error {
+	upgrade := func(m SchemaMigration, file string) (SchemaMigration, error) {
+		if m.ChecksumVersion.recorded() == s.algorithm {
+			return m, nil
+		}
+		b, err := s.src.readFile(file)
+		if err != nil {
+			return m, err
+		}
+		ok, err := checksumMatches(file, b, m.ChecksumVersion.recorded(), m.Checksum)
+		if err != nil || !ok {
+			return m, err
+		}
+		current, err := fileChecksum(file, b, s.algorithm)
+		if err != nil {
+			return m, err
+		}
+		m.Checksum, m.ChecksumVersion = current, s.algorithm
+		s.upgraded = append(s.upgraded, m)
+		return m, nil
+	}
+	for _, v := range s.appliedOrder {
+		file, ok := s.files[v]
+		if _, isGo := s.goMigrations[v]; isGo || !ok {
+			continue
+		}
+		m, err := upgrade(s.applied[v], file)
+		if err != nil {
+			return err
+		}
+		s.applied[v] = m
+	}
+	for _, v := range s.repeatables {
+		m, ok := s.repeatableApplied[v]
+		if !ok {
+			continue
+		}
+		m, err := upgrade(m, s.repeatableFiles[v])
+		if err != nil {
+			return err
+		}
+		s.repeatableApplied[v] = m
+	}
+	return nil
+}
+
+// saveChecksumUpgrades writes the rows upgraded by upgradeChecksums.
+func (s *migrationState) saveChecksumUpgrades(db *gorm.DB) error {
+	for _, m := range s.upgraded {
+		if err := db.Table(s.tables.historyTable()).Where("version = ?", m.Version).Updates(map[string]any{
+			"checksum":         m.Checksum,
+			"checksum_version": m.ChecksumVersion,
+		}).Error; err != nil {
+			return fmt.Errorf("upgrade checksum of %s: %w", m.Version, err)
+		}
+	}
+	s.upgraded = nil
+	return nil
+}
+
+// upgradeManifestChecksums moves manifest entries written with another
+// algorithm to the package algorithm when their file still matches. Go
+// migrations and missing files are left alone.
+func upgradeManifestChecksums(src migrationSource, manifest *ManifestLock) (bool, error) {
+	algorithm := packageChecksumAlgorithm()
+	changed := false
+	for i := range manifest.Migrations {
+		entry := &manifest.Migrations[i]
+		if entry.ChecksumVersion.recorded() == algorithm || strings.HasSuffix(entry.Name, goManifestSuffix) {
+			continue
+		}
+		b, err := src.readFile(src.path(entry.Name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		ok, err := checksumMatches(entry.Name, b, entry.ChecksumVersion.recorded(), entry.SQLSHA256)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		if entry.SQLSHA256, err = fileChecksum(entry.Name, b, algorithm); err != nil {
+			return false, err
+		}
+		entry.ChecksumVersion = algorithm
+		changed = true
+	}
+	if changed {
+		manifest.Version++
+	}
+	return changed, nil
+}
@@ -0,0 +1,199 @@
+package driftflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNormalizedChecksumIgnoresLineEndings(t *testing.T) {
+	lf := []byte("-- +migrate Up\nCREATE TABLE users(id int);  \n\n-- +migrate Down\nDROP TABLE users;\n")
+	crlf := []byte(strings.ReplaceAll("-- +migrate Up\nCREATE TABLE users(id int);\n\n-- +migrate Down\nDROP TABLE users;\t\n", "\n", "\r\n"))
+
+	for _, a := range []ChecksumAlgorithm{ChecksumNormalized, ChecksumNormalizedNoComments} {
+		x, err := fileChecksum("001_users.sql", lf, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := fileChecksum("001_users.sql", crlf, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x != y {
+			t.Fatalf("algorithm %d: expected equal checksums for LF and CRLF", a)
+		}
+	}
+	x, _ := fileChecksum("001_users.sql", lf, ChecksumRaw)
+	y, _ := fileChecksum("001_users.sql", crlf, ChecksumRaw)
+	if x == y {
+		t.Fatalf("raw checksums must differ")
+	}
+}
+
+func TestNormalizedChecksumDetectsChanges(t *testing.T) {
+	base := formatMigrationFile("CREATE TABLE users(id int);", "DROP TABLE users;")
+	commented := formatMigrationFile("-- users table\nCREATE TABLE users(id int);", "DROP TABLE users;")
+	noTx := strings.Replace(base, migrationUpMarker, migrationUpMarker+" notransaction", 1)
+
+	sum := func(content string, a ChecksumAlgorithm) string {
+		t.Helper()
+		c, err := fileChecksum("001_users.sql", []byte(content), a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	if sum(base, ChecksumNormalized) == sum(commented, ChecksumNormalized) {
+		t.Fatalf("comments must count with ChecksumNormalized")
+	}
+	if sum(base, ChecksumNormalizedNoComments) != sum(commented, ChecksumNormalizedNoComments) {
+		t.Fatalf("comments must not count with ChecksumNormalizedNoComments")
+	}
+	if sum(base, ChecksumNormalized) == sum(noTx, ChecksumNormalized) {
+		t.Fatalf("marker options must count")
+	}
+	if _, err := fileChecksum("001_users.sql", []byte(base), 9); err == nil {
+		t.Fatalf("expected unknown algorithm error")
+	}
+}
+
+func TestNormalizedChecksumCoversDirectives(t *testing.T) {
+	base := formatMigrationFile("UPDATE users SET email = lower(email);", "")
+	sum := func(content string) string {
+		t.Helper()
+		c, err := fileChecksum("004_emails.sql", []byte(content), ChecksumNormalized)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	seen := map[string]string{"plain": sum(base)}
+	for name, header := range map[string]string{
+		"irreversible": migrationIrreversibleMarker,
+		"depends on":   migrationDependsOnMarker + " 001_users",
+		"depends on 2": migrationDependsOnMarker + " 001_users,002_posts",
+		"squashes":     migrationSquashesMarker + " 001_users",
+	} {
+		c := sum(header + "\n" + base)
+		for other, o := range seen {
+			if c == o {
+				t.Fatalf("%s and %s have the same checksum", name, other)
+			}
+		}
+		seen[name] = c
+	}
+
+	// spacing of a directive does not count
+	if sum(migrationDependsOnMarker+" 001_users, 002_posts\n"+base) != seen["depends on 2"] {
+		t.Fatalf("expected DependsOn to be hashed canonically")
+	}
+}
+
+func TestUpgradeChecksums(t *testing.T) {
+	dir := writePlanFixtures(t)
+	raw := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name+".sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sha256Hex(b)
+	}
+	s := newTestState(t, dir, "001_users", "002_posts")
+	s.applied["001_users"] = SchemaMigration{Version: "001_users", Checksum: raw("001_users")}
+	s.applied["002_posts"] = SchemaMigration{Version: "002_posts", Checksum: "edited"}
+
+	if err := s.upgradeChecksums(); err != nil {
+		t.Fatalf("upgradeChecksums: %v", err)
+	}
+	want, _ := s.checksum("001_users")
+	if m := s.applied["001_users"]; m.Checksum != want || m.ChecksumVersion != DefaultChecksumAlgorithm {
+		t.Fatalf("expected upgraded row, got %+v", m)
+	}
+	if len(s.upgraded) != 1 || s.upgraded[0].Version != "001_users" {
+		t.Fatalf("expected one row to save, got %+v", s.upgraded)
+	}
+	if _, err := s.planUp(); err == nil || !strings.Contains(err.Error(), "modified after applied: 002_posts") {
+		t.Fatalf("expected the edited migration to stay modified, got %v", err)
+	}
+}
+
+func TestUpgradeManifestChecksums(t *testing.T) {
+	content := formatMigrationFile("CREATE TABLE users(id int);", "DROP TABLE users;")
+	fsys := fstest.MapFS{"001_users.sql": {Data: []byte(content)}}
+	src := fsSource(fsys)
+	manifest := &ManifestLock{Migrations: []ManifestEntry{
+		{Name: "001_users.sql", SQLSHA256: sha256Hex([]byte(content))},
+		{Name: "002_gone.sql", SQLSHA256: "x"},
+	}}
+
+	changed, err := upgradeManifestChecksums(src, manifest)
+	if err != nil || !changed {
+		t.Fatalf("expected an upgrade, got %v, %v", changed, err)
+	}
+	if e := manifest.Migrations[0]; e.ChecksumVersion != DefaultChecksumAlgorithm || e.SQLSHA256 == sha256Hex([]byte(content)) {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if manifest.Migrations[1].ChecksumVersion != 0 {
+		t.Fatalf("missing files must be left alone: %+v", manifest.Migrations[1])
+	}
+
+	// a CRLF checkout of the same file still matches the upgraded entry
+	fsys["001_users.sql"] = &fstest.MapFile{Data: []byte(strings.ReplaceAll(content, "\n", "\r\n"))}
+	manifest.Migrations = manifest.Migrations[:1]
+	issues, err := validateManifest(src, manifest)
+	if err != nil || len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v, %v", issues, err)
+	}
+}
+
+func TestUpAfterCRLFCheckoutWithRawManifest(t *testing.T) {
+	dir := writePlanFixtures(t)
+	raw := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sha256Hex(b)
+	}
+	manifest := &ManifestLock{Migrations: []ManifestEntry{}}
+	for _, name := range []string{"001_users.sql", "002_posts.sql", "003_tags.sql"} {
+		manifest.Migrations = append(manifest.Migrations, ManifestEntry{Name: name, SQLSHA256: raw(name)})
+	}
+	if err := saveManifest(filepath.Join(dir, manifestFile), manifest); err != nil {
+		t.Fatalf("saveManifest: %v", err)
+	}
+	db := openTestDB(t)
+	if err := UpWithOptions(db, dir, MigrateOptions{}); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	// a history row written before checksum algorithms were recorded
+	if err := db.Table(defaultHistoryTable).Where("version = ?", "001_users").Updates(map[string]any{
+		"checksum":         raw("001_users.sql"),
+		"checksum_version": 0,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"001_users.sql", "002_posts.sql", "003_tags.sql"} {
+		path := filepath.Join(dir, name)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(strings.ReplaceAll(string(b), "\n", "\r\n")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := UpWithOptions(db, dir, MigrateOptions{}); err != nil {
+		t.Fatalf("Up after CRLF checkout: %v", err)
+	}
+	var row SchemaMigration
+	if err := db.Table(defaultHistoryTable).Where("version = ?", "001_users").First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.ChecksumVersion != DefaultChecksumAlgorithm {
+		t.Fatalf("expected the legacy row to be upgraded, got %+v", row)
+	}
+}
@@ -1,6 +1,7 @@
 package driftflow
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"path"
@@ -9,10 +10,8 @@ import (
 	"gorm.io/gorm"
 )
 
-const (
-	migrationsHistoryTable = "migrations_history"
-	schemaMigrationsTable  = "schema_migrations"
-)
+// schemaMigrationsTable is the history table of older releases.
+const schemaMigrationsTable = "schema_migrations"
 
 // CleanOptions controls how DriftFlow truncates data without dropping tables.
 type CleanOptions struct {
@@ -23,6 +22,8 @@ type CleanOptions struct {
 	ExcludePattern string
 	KeepMigrations bool
 	DryRun         bool
+	// Tables names the history tables kept by KeepMigrations.
+	Tables MetaTables
 }
 
 // CleanSummary describes the outcome of a clean operation.
@@ -36,6 +37,11 @@ type CleanSummary struct {
 	Statements     []string
 }
 
+// CleanContext is Clean bound to ctx.
+func CleanContext(ctx context.Context, db *gorm.DB, opts CleanOptions) (CleanSummary, error) {
+	return Clean(db.WithContext(ctx), opts)
+}
+
 // Clean truncates all data in the target database/schema without dropping tables.
 func Clean(db *gorm.DB, opts CleanOptions) (CleanSummary, error) {
 	dialect := strings.ToLower(db.Dialector.Name())
@@ -80,7 +86,8 @@ func Clean(db *gorm.DB, opts CleanOptions) (CleanSummary, error) {
 		return CleanSummary{}, err
 	}
 
-	tables = filterTables(tables, opts.IncludePattern, opts.ExcludePattern, opts.KeepMigrations)
+	keep := keptTables(opts, schema, tables)
+	tables = filterTables(tables, opts.IncludePattern, opts.ExcludePattern, keep)
 	summary.TablesAffected = len(tables)
 	if len(tables) == 0 {
 		summary.Method = "none"
@@ -101,10 +108,10 @@ func Clean(db *gorm.DB, opts CleanOptions) (CleanSummary, error) {
 	return summary, nil
 }
 
-func filterTables(tables []string, includePattern, excludePattern string, keepMigrations bool) []string {
+func filterTables(tables []string, includePattern, excludePattern string, keep map[string]bool) []string {
 	filtered := make([]string, 0, len(tables))
 	for _, table := range tables {
-		if keepMigrations && isMigrationTable(table) {
+		if keep[table] {
 			continue
 		}
 		if includePattern != "" && !matchTablePattern(includePattern, table) {
@@ -118,8 +125,44 @@ func filterTables(tables []string, includePattern, excludePattern string, keepMi
 	return filtered
 }
 
-func isMigrationTable(table string) bool {
-	return table == migrationsHistoryTable || table == schemaMigrationsTable
+// keptTables returns the tables of schema Clean never truncates: the lock
+// table, which a running migration may hold, and with KeepMigrations every
+// history table.
+func keptTables(opts CleanOptions, schema string, tables []string) map[string]bool {
+	keep := map[string]bool{lockTableName: true}
+	if opts.KeepMigrations {
+		for name := range migrationTables(opts.Tables.resolve(), schema, tables) {
+			keep[name] = true
+		}
+	}
+	return keep
+}
+
+// migrationTables returns the history tables found among the tables of
+// schema: those of t, the default and legacy schema_migrations ones, and
+// those of any other migration set or tenant, recognised by their
+// "_failures" companion.
+func migrationTables(t MetaTables, schema string, tables []string) map[string]bool {
+	keep := map[string]bool{
+		schemaMigrationsTable:             true,
+		defaultHistoryTable:               true,
+		defaultHistoryTable + "_failures": true,
+	}
+	if t.Schema == "" || t.Schema == schema {
+		keep[t.History] = true
+		keep[t.History+"_failures"] = true
+	}
+	present := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		present[table] = true
+	}
+	for _, table := range tables {
+		if present[table+"_failures"] {
+			keep[table] = true
+			keep[table+"_failures"] = true
+		}
+	}
+	return keep
 }
 
 func matchTablePattern(pattern, table string) bool {
@@ -2,12 +2,15 @@ package cli
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
@@ -23,12 +26,19 @@ import (
 )
 
 var (
-	dsn        string
-	driver     string
-	migDir     string
-	seedGenDir string
-	seedRunDir string
-	modelsDir  string
+	dsn         string
+	driver      string
+	migDir      string
+	seedGenDir  string
+	seedRunDir  string
+	modelsDir   string
+	lockWait    time.Duration
+	outOfOrder  bool
+	stmtTimeout time.Duration
+	lockTimeout time.Duration
+	metaTables  driftflow.MetaTables
+	environment string
+	forceIrrev  bool
 )
 
 // NewRootCommand builds the DriftFlow CLI root command. It can be used by
@@ -50,6 +60,16 @@ func NewRootCommand() *cobra.Command {
 	rootCmd.PersistentFlags().StringVar(&seedRunDir, "seeds", cfg.SeedRunDir, "seed run directory")
 	rootCmd.PersistentFlags().StringVar(&seedGenDir, "seed-gen-dir", cfg.SeedGenDir, "seed generation directory")
 	rootCmd.PersistentFlags().StringVar(&modelsDir, "models", cfg.ModelsDir, "models directory")
+	rootCmd.PersistentFlags().StringVar(&environment, "env", "", "environment selecting env: migration sections (default $ENV)")
+	rootCmd.PersistentFlags().StringVar(&metaTables.Schema, "meta-schema", "", "schema of the DriftFlow metadata tables")
+	rootCmd.PersistentFlags().StringVar(&metaTables.History, "history-table", "", "migration history table (default migrations_history)")
+	rootCmd.PersistentFlags().StringVar(&metaTables.Audit, "audit-table", "", "audit log table (default schema_audit_log)")
+	rootCmd.PersistentFlags().StringVar(&metaTables.FieldHistory, "field-history-table", "", "field history table (default schema_field_history)")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if metaTables != (driftflow.MetaTables{}) {
+			driftflow.SetMetaTables(metaTables)
+		}
+	}
 
 	rootCmd.AddCommand(Commands(cfg)...)
 
@@ -65,6 +85,127 @@ func openDB() (*gorm.DB, error) {
 	return driftflow.ConnectToDB(dsn, driver)
 }
 
+// migrateOptions builds the runner options shared by commands that apply or
+// revert migrations.
+func migrateOptions() driftflow.MigrateOptions {
+	return driftflow.MigrateOptions{
+		LockWaitTimeout:   lockWait,
+		AllowOutOfOrder:   outOfOrder,
+		StatementTimeout:  stmtTimeout,
+		LockTimeout:       lockTimeout,
+		Environment:       environment,
+		ForceIrreversible: forceIrrev,
+	}
+}
+
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&lockWait, "lock-wait", driftflow.DefaultLockWaitTimeout, "how long to wait for the migration lock held by another process")
+}
+
+func addIrreversibleFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&forceIrrev, "force-irreversible", false, "roll back irreversible migrations anyway (nothing is undone without a Down section)")
+}
+
+func addTimeoutFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&stmtTimeout, "timeout", 0, "maximum duration of each migration statement (0 disables it)")
+	cmd.Flags().DurationVar(&lockTimeout, "lock-timeout", 0, "maximum time a statement waits for table or row locks (0 keeps the server default)")
+}
+
+// signalContext returns the command context cancelled on SIGINT or SIGTERM,
+// so an interrupted deploy aborts the running statement.
+func signalContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+}
+
+// tenantFlags selects the tenants of up --tenants and status --tenants.
+type tenantFlags struct {
+	enabled         bool
+	schemas         []string
+	query           string
+	parallel        int
+	continueOnError bool
+}
+
+func addTenantFlags(cmd *cobra.Command, f *tenantFlags) {
+	cmd.Flags().BoolVar(&f.enabled, "tenants", false, "run for every tenant of --tenant-schemas or --tenant-query")
+	cmd.Flags().StringSliceVar(&f.schemas, "tenant-schemas", nil, "comma-separated tenant schemas (postgres)")
+	cmd.Flags().StringVar(&f.query, "tenant-query", "", "SQL listing the tenants with columns name, schema and dsn")
+	cmd.Flags().IntVar(&f.parallel, "parallel", 1, "number of tenants processed at once")
+	cmd.Flags().BoolVar(&f.continueOnError, "continue-on-error", false, "keep going with the remaining tenants after a failure")
+}
+
+func (f tenantFlags) options() (driftflow.TenantOptions, error) {
+	opts := driftflow.TenantOptions{MigrateOptions: migrateOptions(), Parallelism: f.parallel}
+	switch {
+	case len(f.schemas) > 0 && f.query != "":
+		return opts, fmt.Errorf("--tenant-schemas y --tenant-query son excluyentes")
+	case len(f.schemas) > 0:
+		opts.Source = driftflow.TenantSchemas(f.schemas...)
+	case f.query != "":
+		opts.Source = driftflow.TenantQuery(f.query)
+	default:
+		return opts, fmt.Errorf("--tenants requiere --tenant-schemas o --tenant-query")
+	}
+	if f.continueOnError {
+		opts.OnFailure = driftflow.TenantContinueOnError
+	}
+	return opts, nil
+}
+
+// printTenantReport writes one line per tenant, or the report as JSON.
+func printTenantReport(out io.Writer, report driftflow.TenantReport, jsonOut bool) error {
+	if jsonOut {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error al serializar el reporte a JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "TENANT\tSTATUS\tAPPLIED\tDURATION\tERROR"); err != nil {
+		return fmt.Errorf("error escribiendo encabezado: %w", err)
+	}
+	for _, r := range report.Results {
+		applied, errText := "-", r.Error
+		if len(r.Applied) > 0 {
+			applied = strings.Join(r.Applied, ",")
+		}
+		if errText == "" {
+			errText = "-"
+		}
+		duration := (time.Duration(r.DurationMs) * time.Millisecond).String()
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Tenant.Name, r.Status, applied, duration, errText); err != nil {
+			return fmt.Errorf("error escribiendo fila %s: %w", r.Tenant.Name, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error al vaciar salida del tabwriter: %w", err)
+	}
+	return nil
+}
+
+func addOutOfOrderFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&outOfOrder, "allow-out-of-order", false, "apply pending migrations older than the latest applied one")
+}
+
+// countArg parses the optional count argument of up, redo, undo and
+// rollback. Given, it must be a positive integer; otherwise def is returned.
+func countArg(args []string, def int) (int, error) {
+	if len(args) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("n debe ser un entero mayor que 0: %q", args[0])
+	}
+	return n, nil
+}
+
 func openDSN(d string) (*gorm.DB, error) {
 	if strings.HasPrefix(d, "postgres://") || strings.HasPrefix(d, "postgresql://") {
 		return gorm.Open(postgres.Open(d), &gorm.Config{})
@@ -78,22 +219,216 @@ func openDSN(d string) (*gorm.DB, error) {
 	return nil, fmt.Errorf("unsupported DSN: %s", d)
 }
 
+// printPlan writes the planned steps with their SQL, or as JSON.
+func printPlan(w io.Writer, plan []driftflow.PlannedMigration, jsonOut bool) error {
+	if jsonOut {
+		if plan == nil {
+			plan = []driftflow.PlannedMigration{}
+		}
+		b, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error al serializar el plan a JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	if len(plan) == 0 {
+		_, err := fmt.Fprintln(w, "No migrations to run")
+		return err
+	}
+	for i, step := range plan {
+		body := step.SQL
+		if step.Go {
+			body = "-- go migration"
+		}
+		if _, err := fmt.Fprintf(w, "-- [%d] %s %s (batch %d)\n%s\n\n", i+1, strings.ToUpper(string(step.Direction)), step.Version, step.Batch, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newPlanCommand() *cobra.Command {
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "plan [version]",
+		Short: "Show the migrations that up (or migrating to version) would run, without executing them",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			target := ""
+			if len(args) == 1 {
+				target = args[0]
+			}
+			db, err := openDB()
+			if err != nil {
+				return err
+			}
+			plan, err := driftflow.PlanWithOptions(db, migDir, target, migrateOptions())
+			if err != nil {
+				return err
+			}
+			return printPlan(cmd.OutOrStdout(), plan, jsonOut)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	addOutOfOrderFlag(cmd)
+	return cmd
+}
+
+func newStatusCommand() *cobra.Command {
+	var (
+		jsonOut bool
+		tenants tenantFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of every migration (disk, manifest and history)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDB()
+			if err != nil {
+				return err
+			}
+			if tenants.enabled {
+				opts, err := tenants.options()
+				if err != nil {
+					return err
+				}
+				report, err := driftflow.StatusTenants(db, migDir, opts)
+				if jsonOut {
+					if perr := printTenantReport(cmd.OutOrStdout(), report, true); perr != nil {
+						return perr
+					}
+					return err
+				}
+				for _, r := range report.Results {
+					if _, perr := fmt.Fprintf(cmd.OutOrStdout(), "== %s (%s)\n", r.Tenant.Name, r.Status); perr != nil {
+						return perr
+					}
+					if r.Status != driftflow.TenantOK {
+						continue
+					}
+					if perr := printStatus(cmd.OutOrStdout(), r.Migrations, false); perr != nil {
+						return perr
+					}
+				}
+				return err
+			}
+			rows, err := driftflow.StatusWithOptions(db, migDir, migrateOptions())
+			if err != nil {
+				return err
+			}
+			return printStatus(cmd.OutOrStdout(), rows, jsonOut)
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "output as JSON")
+	addTenantFlags(cmd, &tenants)
+	return cmd
+}
+
+func printStatus(out io.Writer, rows []driftflow.MigrationStatus, jsonOut bool) error {
+	if jsonOut {
+		if rows == nil {
+			rows = []driftflow.MigrationStatus{}
+		}
+		b, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error al serializar el estado a JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "VERSION\tSTATE\tBATCH\tAPPLIED_AT\tMANIFEST"); err != nil {
+		return fmt.Errorf("error escribiendo encabezado: %w", err)
+	}
+	for _, r := range rows {
+		state, batch, appliedAt, manifest := string(r.State), "-", "-", "ok"
+		if r.AppliedOutOfOrder {
+			state += " (out of order)"
+		}
+		if r.AppliedAt != nil {
+			batch = strconv.Itoa(r.Batch)
+			appliedAt = r.AppliedAt.Format(time.RFC3339)
+		}
+		if r.Manifest != "" {
+			manifest = string(r.Manifest)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Version, state, batch, appliedAt, manifest); err != nil {
+			return fmt.Errorf("error escribiendo fila %s: %w", r.Version, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error al vaciar salida del tabwriter: %w", err)
+	}
+	return nil
+}
+
 func newUpCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "up",
-		Short: "Apply pending migrations",
+	var (
+		dryRun  bool
+		jsonOut bool
+		tenants tenantFlags
+	)
+
+	cmd := &cobra.Command{
+		Use:   "up [n]",
+		Short: "Apply pending migrations, or only the next n",
+		Args:  cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := countArg(args, 0)
+			if err != nil {
+				return err
+			}
 			db, err := openDB()
 			if err != nil {
 				return err
 			}
-			return driftflow.Up(db, migDir)
+			if dryRun && tenants.enabled {
+				return fmt.Errorf("--dry-run no está soportado con --tenants")
+			}
+			if n > 0 && tenants.enabled {
+				return fmt.Errorf("up N no está soportado con --tenants")
+			}
+			if dryRun {
+				plan, err := driftflow.PlanUpSteps(db, migDir, n, migrateOptions())
+				if err != nil {
+					return err
+				}
+				return printPlan(cmd.OutOrStdout(), plan, false)
+			}
+			ctx, stop := signalContext(cmd)
+			defer stop()
+			if n > 0 {
+				return driftflow.UpStepsWithOptions(db.WithContext(ctx), migDir, n, migrateOptions())
+			}
+			if tenants.enabled {
+				opts, err := tenants.options()
+				if err != nil {
+					return err
+				}
+				report, err := driftflow.UpTenants(db.WithContext(ctx), migDir, opts)
+				if perr := printTenantReport(cmd.OutOrStdout(), report, jsonOut); perr != nil {
+					return perr
+				}
+				return err
+			}
+			return driftflow.UpContext(ctx, db, migDir, migrateOptions())
 		},
 	}
+	addLockFlags(cmd)
+	addOutOfOrderFlag(cmd)
+	addTimeoutFlags(cmd)
+	addTenantFlags(cmd, &tenants)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrations and SQL without executing them")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "print the tenant report as JSON (with --tenants)")
+	return cmd
 }
 
 func newDownCommand() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "down [version]",
 		Short: "Rollback migrations after the given version",
 		Args:  cobra.ExactArgs(1),
@@ -102,55 +437,177 @@ func newDownCommand() *cobra.Command {
 			if err != nil {
 				return err
 			}
-			return driftflow.Down(db, migDir, args[0])
+			return driftflow.MigrateToWithOptions(db, migDir, args[0], migrateOptions())
 		},
 	}
+	addLockFlags(cmd)
+	addOutOfOrderFlag(cmd)
+	addIrreversibleFlag(cmd)
+	return cmd
 }
 
-func newUndoCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "undo [n]",
-		Short: "Rollback the last n migrations (default 1)",
+func newGotoCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "goto VERSION",
+		Short: "Apply or rollback migrations until VERSION is the latest applied",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDB()
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				plan, err := driftflow.PlanWithOptions(db, migDir, args[0], migrateOptions())
+				if err != nil {
+					return err
+				}
+				return printPlan(cmd.OutOrStdout(), plan, false)
+			}
+			ctx, stop := signalContext(cmd)
+			defer stop()
+			return driftflow.MigrateToContext(ctx, db, migDir, args[0], migrateOptions())
+		},
+	}
+	addLockFlags(cmd)
+	addOutOfOrderFlag(cmd)
+	addTimeoutFlags(cmd)
+	addIrreversibleFlag(cmd)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrations and SQL without executing them")
+	return cmd
+}
+
+func newRedoCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "redo [n]",
+		Short: "Rollback and re-apply the last n migrations (default 1)",
 		Args:  cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			steps := 1
-			if len(args) == 1 {
-				var err error
-				steps, err = strconv.Atoi(args[0])
+			n, err := countArg(args, 1)
+			if err != nil {
+				return err
+			}
+			db, err := openDB()
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				plan, err := driftflow.PlanRedo(db, migDir, n, migrateOptions())
 				if err != nil {
 					return err
 				}
+				return printPlan(cmd.OutOrStdout(), plan, false)
 			}
+			ctx, stop := signalContext(cmd)
+			defer stop()
+			return driftflow.RedoWithOptions(db.WithContext(ctx), migDir, n, migrateOptions())
+		},
+	}
+	addLockFlags(cmd)
+	addTimeoutFlags(cmd)
+	addIrreversibleFlag(cmd)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrations and SQL without executing them")
+	return cmd
+}
+
+func newBaselineCommand() *cobra.Command {
+	var verify bool
+
+	cmd := &cobra.Command{
+		Use:   "baseline [version]",
+		Short: "Mark migrations up to version as applied without running them",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
 			db, err := openDB()
 			if err != nil {
 				return err
 			}
-			return driftflow.DownSteps(db, migDir, steps)
+			return driftflow.BaselineWithOptions(db, migDir, args[0], driftflow.BaselineOptions{
+				MigrateOptions: migrateOptions(),
+				VerifySchema:   verify,
+			})
 		},
 	}
+	addLockFlags(cmd)
+	cmd.Flags().BoolVar(&verify, "verify-schema", false, "check the live schema against schema.lock.json first")
+	return cmd
 }
 
-func newRollbackCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "rollback [n]",
+func newUndoCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "undo [n]",
 		Short: "Rollback the last n migrations (default 1)",
 		Args:  cobra.RangeArgs(0, 1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			steps := 1
-			if len(args) == 1 {
-				var err error
-				steps, err = strconv.Atoi(args[0])
+			steps, err := countArg(args, 1)
+			if err != nil {
+				return err
+			}
+			db, err := openDB()
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				plan, err := driftflow.PlanDownStepsWithOptions(db, migDir, steps, migrateOptions())
 				if err != nil {
 					return err
 				}
+				return printPlan(cmd.OutOrStdout(), plan, false)
+			}
+			return driftflow.DownStepsWithOptions(db, migDir, steps, migrateOptions())
+		},
+	}
+	addLockFlags(cmd)
+	addIrreversibleFlag(cmd)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrations and SQL without executing them")
+	return cmd
+}
+
+func newRollbackCommand() *cobra.Command {
+	var dryRun bool
+	var byBatch bool
+
+	cmd := &cobra.Command{
+		Use:   "rollback [n]",
+		Short: "Rollback the last n migrations, or the last n batches with --batch (default 1)",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := countArg(args, 1)
+			if err != nil {
+				return err
 			}
 			db, err := openDB()
 			if err != nil {
 				return err
 			}
-			return driftflow.DownSteps(db, migDir, steps)
+			if dryRun {
+				var plan []driftflow.PlannedMigration
+				if byBatch {
+					plan, err = driftflow.PlanRollbackBatchWithOptions(db, migDir, n, migrateOptions())
+				} else {
+					plan, err = driftflow.PlanDownStepsWithOptions(db, migDir, n, migrateOptions())
+				}
+				if err != nil {
+					return err
+				}
+				return printPlan(cmd.OutOrStdout(), plan, false)
+			}
+			if byBatch {
+				return driftflow.RollbackBatchWithOptions(db, migDir, n, migrateOptions())
+			}
+			return driftflow.DownStepsWithOptions(db, migDir, n, migrateOptions())
 		},
 	}
+	addLockFlags(cmd)
+	addIrreversibleFlag(cmd)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the migrations and SQL without executing them")
+	cmd.Flags().BoolVar(&byBatch, "batch", false, "rollback whole deploy batches instead of single migrations")
+	return cmd
 }
 
 func newSeedCommand() *cobra.Command {
@@ -202,8 +659,9 @@ func newSeedgenCommand() *cobra.Command {
 func newGenerateCommand() *cobra.Command {
 	var repair bool
 	var adopt bool
+	var engines []string
 
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "generate",
 		Short: "Generate migration files from models (snapshot + incremental)",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -216,6 +674,7 @@ func newGenerateCommand() *cobra.Command {
 				Dir:          migDir,
 				ManifestMode: driftflow.ManifestStrict, // default
 				Engine:       driver,
+				Engines:      engines,
 			}
 
 			if repair {
@@ -226,6 +685,8 @@ func newGenerateCommand() *cobra.Command {
 			return driftflow.GenerateModelMigrations(models, opts)
 		},
 	}
+	cmd.Flags().StringSliceVar(&engines, "engines", nil, "generate one migration tree per engine under the migrations directory (e.g. postgres,sqlserver)")
+	return cmd
 
 	/*cmd.Flags().BoolVar(&repair, "repair", false, "Repair modified migration files (recalculate hashes)")
 	cmd.Flags().BoolVar(&adopt, "adopt", false, "Adopt untracked migration files into manifest (requires --repair)")
@@ -233,6 +694,41 @@ func newGenerateCommand() *cobra.Command {
 	return cmd*/
 }
 
+func newSquashCommand() *cobra.Command {
+	var (
+		through string
+		dryRun  bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "squash",
+		Short: "Replace the migrations up to --through with one regenerated baseline",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res, err := driftflow.Squash(driftflow.SquashOptions{
+				Dir:     migDir,
+				Through: through,
+				Engine:  driver,
+				DryRun:  dryRun,
+			})
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if dryRun {
+				_, err := fmt.Fprintf(out, "-- %s replaces %d migrations\n-- +migrate Up\n%s\n\n-- +migrate Down\n%s\n",
+					res.Version, len(res.Squashed), res.Up, res.Down)
+				return err
+			}
+			_, err = fmt.Fprintf(out, "Wrote %s replacing %d migrations (%d tables)\n", res.File, len(res.Squashed), len(res.Tables))
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&through, "through", "", "last migration version to squash (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the baseline without changing any file")
+	_ = cmd.MarkFlagRequired("through")
+	return cmd
+}
+
 func newMigrateCommand() *cobra.Command {
 	return &cobra.Command{
 		Use:   "migrate",
@@ -412,12 +908,12 @@ func newAuditListCommand() *cobra.Command {
 			}
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 			//  Manejo de error en encabezado
-			if _, err := fmt.Fprintln(w, "ID\tVERSION\tCOMMIT\tACTION\tUSER\tHOST\tLOGGED_AT"); err != nil {
+			if _, err := fmt.Fprintln(w, "ID\tVERSION\tCOMMIT\tACTION\tUSER\tHOST\tLOGGED_AT\tDETAIL"); err != nil {
 				return fmt.Errorf("error escribiendo encabezado: %w", err)
 			}
 			for _, l := range logs {
-				if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
-					l.ID, l.Version, l.Commit, l.Action, l.User, l.Host, l.LoggedAt.Format(time.RFC3339),
+				if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					l.ID, l.Version, l.Commit, l.Action, l.User, l.Host, l.LoggedAt.Format(time.RFC3339), l.Detail,
 				); err != nil {
 					return fmt.Errorf("error escribiendo fila de log ID %d: %w", l.ID, err)
 				}
@@ -485,6 +981,87 @@ func newAuditExportCommand() *cobra.Command {
 	return cmd
 }
 
+func newHistoryCommand() *cobra.Command {
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Repair the migration history without running migrations",
+	}
+	historyCmd.AddCommand(newHistoryRepairCommand("force VERSION",
+		"Record migrations up to VERSION as applied and later ones as pending", cobra.ExactArgs(1),
+		func(db *gorm.DB, args []string, opts driftflow.HistoryOptions) ([]driftflow.HistoryChange, error) {
+			return driftflow.ForceVersion(db, migDir, args[0], opts)
+		}))
+	historyCmd.AddCommand(newHistoryRepairCommand("mark-applied VERSION...",
+		"Record migrations as applied without running them", cobra.MinimumNArgs(1),
+		func(db *gorm.DB, args []string, opts driftflow.HistoryOptions) ([]driftflow.HistoryChange, error) {
+			return driftflow.MarkApplied(db, migDir, args, opts)
+		}))
+	historyCmd.AddCommand(newHistoryRepairCommand("mark-pending VERSION...",
+		"Remove migrations from the history without rolling them back", cobra.MinimumNArgs(1),
+		func(db *gorm.DB, args []string, opts driftflow.HistoryOptions) ([]driftflow.HistoryChange, error) {
+			return driftflow.MarkPending(db, migDir, args, opts)
+		}))
+	historyCmd.AddCommand(newHistoryRepairCommand("rechecksum VERSION...",
+		"Accept the current checksum of edited applied migrations", cobra.MinimumNArgs(1),
+		func(db *gorm.DB, args []string, opts driftflow.HistoryOptions) ([]driftflow.HistoryChange, error) {
+			return driftflow.Rechecksum(db, migDir, args, opts)
+		}))
+	return historyCmd
+}
+
+// newHistoryRepairCommand builds a history subcommand around one of the
+// history repair functions.
+func newHistoryRepairCommand(use, short string, args cobra.PositionalArgs,
+	repair func(db *gorm.DB, args []string, opts driftflow.HistoryOptions) ([]driftflow.HistoryChange, error)) *cobra.Command {
+	var (
+		reason string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  args,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := openDB()
+			if err != nil {
+				return err
+			}
+			changes, err := repair(db, args, driftflow.HistoryOptions{
+				MigrateOptions: migrateOptions(),
+				Reason:         reason,
+				DryRun:         dryRun,
+			})
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if len(changes) == 0 {
+				_, err := fmt.Fprintln(out, "History already up to date")
+				return err
+			}
+			for _, c := range changes {
+				line := fmt.Sprintf("%s %s", c.Action, c.Version)
+				if c.Action == driftflow.HistoryRechecksum {
+					line += fmt.Sprintf(" (%s -> %s)", c.OldChecksum, c.NewChecksum)
+				}
+				if dryRun {
+					line = "[dry-run] " + line
+				}
+				if _, err := fmt.Fprintln(out, line); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	addLockFlags(cmd)
+	cmd.Flags().StringVar(&reason, "reason", "", "why the history is changed, stored in the audit log (required)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the changes without writing them")
+	_ = cmd.MarkFlagRequired("reason")
+	return cmd
+}
+
 func newCompareCommand() *cobra.Command {
 	var fromDSN, toDSN string
 	cmd := &cobra.Command{
@@ -538,7 +1115,12 @@ func Commands(cfg *config.Config) []*cobra.Command {
 
 	return []*cobra.Command{
 		newUpCommand(),
+		newPlanCommand(),
+		newStatusCommand(),
 		newDownCommand(),
+		newGotoCommand(),
+		newRedoCommand(),
+		newBaselineCommand(),
 		newUndoCommand(),
 		newRollbackCommand(),
 		newResetCommand(),
@@ -546,9 +1128,11 @@ func Commands(cfg *config.Config) []*cobra.Command {
 		newSeedCommand(),
 		newSeedgenCommand(),
 		newGenerateCommand(),
+		newSquashCommand(),
 		newMigrateCommand(),
 		newValidateCommand(),
 		newAuditCommand(),
+		newHistoryCommand(),
 		newCompareCommand(),
 	}
 }
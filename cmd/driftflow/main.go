@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 
+	driftflow "github.com/misaelcrespo30/DriftFlow"
 	driftcli "github.com/misaelcrespo30/DriftFlow/cli"
 	"github.com/misaelcrespo30/DriftFlow/config"
 	"github.com/spf13/cobra"
@@ -12,6 +14,10 @@ import (
 func main() {
 	cfg := config.Load()
 
+	// warnings and failures the library reports without returning them
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	driftflow.SetObserver(driftflow.SlogObserver(logger))
+
 	root := &cobra.Command{Use: "driftflow"}
 	root.AddCommand(driftcli.Commands(cfg)...)
 
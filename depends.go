@@ -0,0 +1,146 @@
+package driftflow
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// loadDependencies reads the DependsOn lists of every migration into s.deps
+// and orders s.versions so each migration follows its dependencies. Versions
+// without dependencies between them keep their lexical order. A dependency on
+// a version replaced by a squashed baseline is a dependency on the baseline.
+func (s *migrationState) loadDependencies() error {
+	s.deps = map[string][]string{}
+	squashedInto := map[string]string{}
+	for _, v := range s.versions {
+		if m, ok := s.goMigrations[v]; ok {
+			if len(m.DependsOn) > 0 {
+				s.deps[v] = m.DependsOn
+			}
+			continue
+		}
+		script, _, err := readMigrationScript(s.src, s.files[v], ChecksumRaw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.files[v], err)
+		}
+		for _, r := range script.Squashes {
+			squashedInto[r] = v
+		}
+		if len(script.DependsOn) > 0 {
+			s.deps[v] = script.DependsOn
+		}
+	}
+	for v, deps := range s.deps {
+		resolved := make([]string, 0, len(deps))
+		for _, d := range deps {
+			if b, ok := squashedInto[d]; ok && !s.known(d) {
+				d = b
+			}
+			if d == v {
+				return fmt.Errorf("migration %s depends on itself", v)
+			}
+			if !s.known(d) {
+				return fmt.Errorf("migration %s depends on missing migration %s", v, d)
+			}
+			resolved = append(resolved, d)
+		}
+		s.deps[v] = resolved
+	}
+	if len(s.deps) == 0 {
+		return nil
+	}
+
+	index := make(map[string]int, len(s.versions))
+	for i, v := range s.versions {
+		index[v] = i
+	}
+	waiting := make(map[string]int, len(s.versions)) // unordered dependencies
+	dependents := map[string][]string{}
+	for v, deps := range s.deps {
+		for _, d := range deps {
+			waiting[v]++
+			dependents[d] = append(dependents[d], v)
+		}
+	}
+	var ready []string
+	for _, v := range s.versions {
+		if waiting[v] == 0 {
+			ready = append(ready, v)
+		}
+	}
+	ordered := make([]string, 0, len(s.versions))
+	for len(ready) > 0 {
+		v := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, v)
+		for _, d := range dependents[v] {
+			if waiting[d]--; waiting[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+		sort.SliceStable(ready, func(i, j int) bool { return index[ready[i]] < index[ready[j]] })
+	}
+	if len(ordered) != len(s.versions) {
+		var cycle []string
+		for _, v := range s.versions {
+			if waiting[v] > 0 {
+				cycle = append(cycle, v)
+			}
+		}
+		return fmt.Errorf("migration dependency cycle among %s", strings.Join(cycle, ", "))
+	}
+	s.versions = ordered
+	return nil
+}
+
+// checkDependencies fails if a step of plan applies a migration before its
+// dependencies or rolls back one that an applied migration depends on.
+func (s *migrationState) checkDependencies(plan []PlannedMigration) error {
+	if len(s.deps) == 0 {
+		return nil
+	}
+	applied := make(map[string]bool, len(s.applied))
+	for v := range s.applied {
+		applied[v] = true
+	}
+	for _, step := range plan {
+		if step.Repeatable {
+			continue
+		}
+		switch step.Direction {
+		case DirectionUp:
+			for _, d := range s.deps[step.Version] {
+				if !applied[d] {
+					return fmt.Errorf("migration %s depends on %s, which is not applied", step.Version, d)
+				}
+			}
+			applied[step.Version] = true
+		case DirectionDown:
+			delete(applied, step.Version)
+			for _, v := range s.versions {
+				if applied[v] && slices.Contains(s.deps[v], step.Version) {
+					return fmt.Errorf("cannot roll back %s: %s depends on it", step.Version, v)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dependenciesApplied reports whether version declares dependencies and all
+// of them are applied, which lets a migration merged from another branch run
+// after newer ones.
+func (s *migrationState) dependenciesApplied(version string) bool {
+	deps := s.deps[version]
+	if len(deps) == 0 {
+		return false
+	}
+	for _, d := range deps {
+		if _, ok := s.applied[d]; !ok {
+			return false
+		}
+	}
+	return true
+}
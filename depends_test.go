@@ -0,0 +1,141 @@
+package driftflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeDependentMigration writes a migration that declares dependencies.
+func writeDependentMigration(t *testing.T, dir, name, dependsOn string) {
+	t.Helper()
+	content := migrationDependsOnMarker + " " + dependsOn + "\n\n" +
+		formatMigrationFile("CREATE TABLE "+name+"(id int);", "DROP TABLE "+name+";")
+	if err := os.WriteFile(filepath.Join(dir, name+".sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseMigrationScriptDependsOn(t *testing.T) {
+	script, err := parseMigrationScript("-- +migrate DependsOn: 001_users, 002_posts\n-- +migrate DependsOn: 003_tags\n-- +migrate Up\nSELECT 1;\n-- +migrate Down\n")
+	if err != nil {
+		t.Fatalf("parseMigrationScript: %v", err)
+	}
+	if strings.Join(script.DependsOn, ",") != "001_users,002_posts,003_tags" {
+		t.Fatalf("unexpected dependencies: %v", script.DependsOn)
+	}
+}
+
+func TestPlanUpOrdersByDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "001_a", "CREATE TABLE a(id int);", "DROP TABLE a;")
+	writeDependentMigration(t, dir, "002_b", "003_c")
+	writeMigration(t, dir, "003_c", "CREATE TABLE c(id int);", "DROP TABLE c;")
+
+	plan, err := newTestState(t, dir).planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	var got []string
+	for _, step := range plan {
+		got = append(got, step.Version)
+	}
+	if strings.Join(got, ",") != "001_a,003_c,002_b" {
+		t.Fatalf("unexpected order: %v", got)
+	}
+}
+
+func TestPlanUpMergedMigrationWithDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "001_a", "CREATE TABLE a(id int);", "DROP TABLE a;")
+	writeDependentMigration(t, dir, "002_b", "001_a")
+	writeMigration(t, dir, "002_x", "CREATE TABLE x(id int);", "DROP TABLE x;")
+	writeMigration(t, dir, "003_c", "CREATE TABLE c(id int);", "DROP TABLE c;")
+
+	// 002_b was merged after 003_c was applied; its dependencies are applied,
+	// so it runs without AllowOutOfOrder
+	s := newTestState(t, dir, "001_a", "002_x", "003_c")
+	plan, err := s.planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Version != "002_b" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	// a migration without dependencies keeps the strict policy
+	s = newTestState(t, dir, "001_a", "002_b", "003_c")
+	if _, err := s.planUp(); !errors.Is(err, ErrOutOfOrder) {
+		t.Fatalf("expected ErrOutOfOrder, got %v", err)
+	}
+}
+
+func TestPlanForceFollowsDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "001_a", "CREATE TABLE a(id int);", "DROP TABLE a;")
+	writeDependentMigration(t, dir, "002_b", "003_c")
+	writeMigration(t, dir, "003_c", "CREATE TABLE c(id int);", "DROP TABLE c;")
+
+	// 002_b runs after 003_c, so forcing 003_c leaves it pending
+	changes, err := newTestState(t, dir).planForce("003_c")
+	if err != nil {
+		t.Fatalf("planForce: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Version != "001_a" || changes[1].Version != "003_c" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	changes, err = newTestState(t, dir, "001_a", "003_c", "002_b").planForce("003_c")
+	if err != nil {
+		t.Fatalf("planForce: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Version != "002_b" || changes[0].Action != HistoryMarkPending {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestDependenciesRefuseMissingAndCycles(t *testing.T) {
+	dir := t.TempDir()
+	writeDependentMigration(t, dir, "001_a", "009_missing")
+	s := &migrationState{src: dirSource(dir), files: map[string]string{"001_a": filepath.Join(dir, "001_a.sql")}, versions: []string{"001_a"}}
+	if err := s.loadDependencies(); err == nil || !strings.Contains(err.Error(), "depends on missing migration 009_missing") {
+		t.Fatalf("expected missing dependency error, got %v", err)
+	}
+
+	dir = t.TempDir()
+	writeDependentMigration(t, dir, "001_a", "002_b")
+	writeDependentMigration(t, dir, "002_b", "001_a")
+	s = &migrationState{
+		src:      dirSource(dir),
+		files:    map[string]string{"001_a": filepath.Join(dir, "001_a.sql"), "002_b": filepath.Join(dir, "002_b.sql")},
+		versions: []string{"001_a", "002_b"},
+	}
+	if err := s.loadDependencies(); err == nil || !strings.Contains(err.Error(), "dependency cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "001_a", "CREATE TABLE a(id int);", "DROP TABLE a;")
+	writeDependentMigration(t, dir, "002_b", "001_a")
+	s := newTestState(t, dir)
+
+	if err := s.checkDependencies([]PlannedMigration{{Version: "002_b", Direction: DirectionUp}}); err == nil ||
+		!strings.Contains(err.Error(), "depends on 001_a, which is not applied") {
+		t.Fatalf("expected missing dependency error, got %v", err)
+	}
+
+	// history written before 002_b declared its dependency
+	s = newTestState(t, dir, "002_b", "001_a")
+	m := s.applied["001_a"]
+	m.Batch = 2
+	s.applied["001_a"] = m
+	if _, err := s.planRollbackBatch(1); err == nil || !strings.Contains(err.Error(), "cannot roll back 001_a: 002_b depends on it") {
+		t.Fatalf("expected rollback refusal, got %v", err)
+	}
+	if _, err := s.planDownSteps(2); err != nil {
+		t.Fatalf("rolling back the dependent first must work: %v", err)
+	}
+}
@@ -20,22 +20,36 @@ func (FieldHistory) TableName() string {
 	return "schema_field_history"
 }
 
-// EnsureFieldHistoryTable ensures the schema_field_history table exists.
+// EnsureFieldHistoryTable ensures the field history table (schema_field_history
+// or the one set with SetMetaTables) exists.
 func EnsureFieldHistoryTable(db *gorm.DB) error {
-	return db.AutoMigrate(&FieldHistory{})
+	return db.Table(packageTables().fieldHistoryTable()).AutoMigrate(&FieldHistory{})
 }
 
-func logFieldAdd(db *gorm.DB, version, table, column, newType string) {
+func logFieldAdd(db *gorm.DB, opts MigrateOptions, version, table, column, newType string) {
 	entry := FieldHistory{Version: version, Table: table, ColumnName: column, NewType: newType}
-	_ = db.Create(&entry).Error
+	writeFieldHistory(db, opts, entry)
 }
 
-func logFieldRemove(db *gorm.DB, version, table, column, oldType string) {
+func logFieldRemove(db *gorm.DB, opts MigrateOptions, version, table, column, oldType string) {
 	entry := FieldHistory{Version: version, Table: table, ColumnName: column, OldType: oldType}
-	_ = db.Create(&entry).Error
+	writeFieldHistory(db, opts, entry)
 }
 
-func logFieldAlter(db *gorm.DB, version, table, column, fromType, toType string) {
+func logFieldAlter(db *gorm.DB, opts MigrateOptions, version, table, column, fromType, toType string) {
 	entry := FieldHistory{Version: version, Table: table, ColumnName: column, OldType: fromType, NewType: toType}
-	_ = db.Create(&entry).Error
+	writeFieldHistory(db, opts, entry)
+}
+
+// writeFieldHistory inserts entry and reports a failure to the observer of
+// opts instead of failing the generation.
+func writeFieldHistory(db *gorm.DB, opts MigrateOptions, entry FieldHistory) {
+	if err := db.Table(opts.tables().fieldHistoryTable()).Create(&entry).Error; err != nil {
+		opts.observer().OnEvent(Event{
+			Kind:    EventAuditWriteFailed,
+			Version: entry.Version,
+			Message: "field history " + entry.Table + "." + entry.ColumnName,
+			Err:     err,
+		})
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -44,6 +45,13 @@ type GenerateOptions struct {
 	ManifestMode       ManifestMode
 	RepairAddUntracked bool // en repair: agrega *.sql fuera del manifest
 	Engine             string
+	// Engines genera un árbol de migraciones por motor en Dir/<motor>, cada
+	// uno con su manifest.lock.json y schema.lock.json y con los mismos
+	// nombres de versión. Reemplaza a Engine.
+	Engines []string
+	// GoMigrations se registran en el manifest de cada árbol que aún no las
+	// tiene, igual que las que se pasan en MigrateOptions.GoMigrations.
+	GoMigrations []GoMigration
 }
 
 type ManifestLock struct {
@@ -53,9 +61,12 @@ type ManifestLock struct {
 }
 
 type ManifestEntry struct {
-	Name       string `json:"name"` // filename, incluye .sql
-	SQLSHA256  string `json:"sql_sha256"`
-	CreatedUTC string `json:"created_utc"`
+	Name      string `json:"name"` // filename, incluye .sql (o version.go para migraciones Go)
+	SQLSHA256 string `json:"sql_sha256"`
+	// ChecksumVersion es el algoritmo de SQLSHA256; vacío en manifests
+	// anteriores (ChecksumRaw) y en migraciones Go
+	ChecksumVersion ChecksumAlgorithm `json:"checksum_version,omitempty"`
+	CreatedUTC      string            `json:"created_utc"`
 }
 
 type ManifestIssueType string
@@ -74,17 +85,26 @@ type manifestLockWire struct {
 }
 
 type manifestEntryWire struct {
-	Name       string `json:"name"`
-	SQLSHA256  string `json:"sql_sha256"`
-	UpSHA256   string `json:"up_sha256"`
-	DownSHA256 string `json:"down_sha256"`
-	CreatedUTC string `json:"created_utc"`
+	Name            string            `json:"name"`
+	SQLSHA256       string            `json:"sql_sha256"`
+	ChecksumVersion ChecksumAlgorithm `json:"checksum_version"`
+	UpSHA256        string            `json:"up_sha256"`
+	DownSHA256      string            `json:"down_sha256"`
+	CreatedUTC      string            `json:"created_utc"`
 }
 
+const manifestFile = "manifest.lock.json"
+
 func loadManifest(path string) (*ManifestLock, error) {
-	b, err := os.ReadFile(path)
+	return readManifest(dirSource(filepath.Dir(path)), path)
+}
+
+// readManifest reads the manifest at path in src. A missing manifest is an
+// empty one.
+func readManifest(src migrationSource, path string) (*ManifestLock, error) {
+	b, err := src.readFile(path)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return &ManifestLock{Version: 0, Migrations: []ManifestEntry{}}, nil
 		}
 		return nil, err
@@ -101,9 +121,10 @@ func loadManifest(path string) (*ManifestLock, error) {
 	for _, entry := range wire.Migrations {
 		name := normalizeManifestName(entry.Name)
 		m.Migrations = append(m.Migrations, ManifestEntry{
-			Name:       name,
-			SQLSHA256:  entry.SQLSHA256,
-			CreatedUTC: entry.CreatedUTC,
+			Name:            name,
+			SQLSHA256:       entry.SQLSHA256,
+			ChecksumVersion: entry.ChecksumVersion,
+			CreatedUTC:      entry.CreatedUTC,
 		})
 	}
 	if m.Migrations == nil {
@@ -129,21 +150,41 @@ func normalizeManifestName(name string) string {
 	if name == "" {
 		return ""
 	}
-	if strings.HasSuffix(name, ".sql") {
+	if strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, goManifestSuffix) {
 		return name
 	}
 	return name + ".sql"
 }
 
-func hashMigrationFile(path string) (string, error) {
-	b, err := os.ReadFile(path)
+// manifestEntryHash returns the hash recorded for a manifest entry: the file
+// checksum under algorithm a for .sql entries, the code-supplied checksum for
+// the Go migrations of src.
+func manifestEntryHash(src migrationSource, name string, a ChecksumAlgorithm) (string, error) {
+	if strings.HasSuffix(name, goManifestSuffix) {
+		version := strings.TrimSuffix(name, goManifestSuffix)
+		m, ok := goMigrationMap(src.goMigrations)[version]
+		if !ok {
+			return "", fmt.Errorf("go migration %s is not in the migration set: %w", version, fs.ErrNotExist)
+		}
+		return m.Checksum, nil
+	}
+	b, err := src.readFile(src.path(name))
 	if err != nil {
 		return "", err
 	}
-	return sha256Hex(b), nil
+	return fileChecksum(name, b, a)
 }
 
-func migrateManifest(dir string, manifest *ManifestLock) (bool, error) {
+// manifestChecksumVersion returns the algorithm recorded for a new hash of
+// name under the package algorithm.
+func manifestChecksumVersion(name string) ChecksumAlgorithm {
+	if strings.HasSuffix(name, goManifestSuffix) {
+		return 0
+	}
+	return packageChecksumAlgorithm()
+}
+
+func migrateManifest(src migrationSource, manifest *ManifestLock) (bool, error) {
 	changed := false
 	for i := range manifest.Migrations {
 		entry := &manifest.Migrations[i]
@@ -153,14 +194,15 @@ func migrateManifest(dir string, manifest *ManifestLock) (bool, error) {
 			changed = true
 		}
 		if entry.SQLSHA256 == "" && entry.Name != "" {
-			hash, err := hashMigrationFile(filepath.Join(dir, entry.Name))
+			hash, err := manifestEntryHash(src, entry.Name, packageChecksumAlgorithm())
 			if err != nil {
-				if errors.Is(err, os.ErrNotExist) {
+				if errors.Is(err, fs.ErrNotExist) {
 					continue
 				}
 				return false, err
 			}
 			entry.SQLSHA256 = hash
+			entry.ChecksumVersion = manifestChecksumVersion(entry.Name)
 			changed = true
 		}
 	}
@@ -170,13 +212,13 @@ func migrateManifest(dir string, manifest *ManifestLock) (bool, error) {
 	return changed, nil
 }
 
-func validateManifest(dir string, manifest *ManifestLock) ([]ManifestIssue, error) {
+func validateManifest(src migrationSource, manifest *ManifestLock) ([]ManifestIssue, error) {
 	entries := make(map[string]ManifestEntry, len(manifest.Migrations))
 	for _, e := range manifest.Migrations {
 		if e.Name == "" {
 			return nil, fmt.Errorf("manifest has empty migration name")
 		}
-		if !strings.HasSuffix(e.Name, ".sql") {
+		if !strings.HasSuffix(e.Name, ".sql") && !strings.HasSuffix(e.Name, goManifestSuffix) {
 			return nil, fmt.Errorf("manifest migration name missing .sql: %s", e.Name)
 		}
 		if _, exists := entries[e.Name]; exists {
@@ -188,15 +230,26 @@ func validateManifest(dir string, manifest *ManifestLock) ([]ManifestIssue, erro
 	var issues []ManifestIssue
 
 	// 1) scan disk and ensure no untracked/missing pairs exist
-	files, _ := filepath.Glob(filepath.Join(dir, "*.sql"))
+	files, _ := src.glob("*.sql")
 
 	diskNames := map[string]struct{}{}
 	for _, p := range files {
 		name := filepath.Base(p)
+		if isCallbackFile(name) {
+			continue
+		}
 		diskNames[name] = struct{}{}
 	}
+	// the Go migrations of the set count as present; a source without any
+	// cannot tell, so its Go entries are left alone
+	for _, m := range src.goMigrations {
+		diskNames[goManifestName(m.Version)] = struct{}{}
+	}
 
 	for name := range entries {
+		if strings.HasSuffix(name, goManifestSuffix) && len(src.goMigrations) == 0 {
+			continue
+		}
 		if _, ok := diskNames[name]; !ok {
 			issues = append(issues, ManifestIssue{Type: IssueMissingPair, Migration: name, Detail: "manifest entry missing migration file"})
 		}
@@ -208,27 +261,44 @@ func validateManifest(dir string, manifest *ManifestLock) ([]ManifestIssue, erro
 		}
 	}
 
-	// 2) verify tracked entries hashes
+	// 2) verify tracked entries hashes; repeatables are meant to be edited,
+	// so only their presence is tracked
 	for name, e := range entries {
-		if _, ok := diskNames[name]; !ok {
+		if _, ok := diskNames[name]; !ok || isRepeatableFile(name) {
 			continue
 		}
-		path := filepath.Join(dir, name)
-
-		hash, err := hashMigrationFile(path)
+		hash, err := manifestEntryHash(src, name, e.ChecksumVersion.recorded())
 		if err != nil {
 			return nil, err
 		}
 
 		if !strings.EqualFold(hash, e.SQLSHA256) {
-			issues = append(issues, ManifestIssue{Type: IssueHashMismatch, Migration: name, File: path, Detail: "SQL hash mismatch"})
+			if strings.HasSuffix(name, goManifestSuffix) {
+				issues = append(issues, ManifestIssue{Type: IssueHashMismatch, Migration: name, Detail: "Go migration checksum mismatch"})
+				continue
+			}
+			issues = append(issues, ManifestIssue{Type: IssueHashMismatch, Migration: name, File: src.path(name), Detail: "SQL hash mismatch"})
 		}
 	}
 
 	return issues, nil
 }
 
-func repairManifest(dir string, manifest *ManifestLock, issues []ManifestIssue, addUntracked bool) error {
+// reportManifestIssues sends each issue to obs.
+func reportManifestIssues(obs Observer, issues []ManifestIssue) {
+	for i := range issues {
+		is := issues[i]
+		obs.OnEvent(Event{
+			Kind:    EventManifestIssue,
+			Version: manifestEntryVersion(is.Migration),
+			File:    is.File,
+			Issue:   &is,
+			Message: is.Detail,
+		})
+	}
+}
+
+func repairManifest(src migrationSource, manifest *ManifestLock, issues []ManifestIssue, addUntracked bool) error {
 	byName := map[string]*ManifestEntry{}
 	for i := range manifest.Migrations {
 		e := &manifest.Migrations[i]
@@ -236,12 +306,7 @@ func repairManifest(dir string, manifest *ManifestLock, issues []ManifestIssue,
 	}
 
 	recalc := func(name string) (string, error) {
-		path := filepath.Join(dir, name)
-		hash, err := hashMigrationFile(path)
-		if err != nil {
-			return "", err
-		}
-		return hash, nil
+		return manifestEntryHash(src, name, packageChecksumAlgorithm())
 	}
 
 	// fix mismatches
@@ -259,6 +324,7 @@ func repairManifest(dir string, manifest *ManifestLock, issues []ManifestIssue,
 			return err
 		}
 		entry.SQLSHA256 = hash
+		entry.ChecksumVersion = manifestChecksumVersion(name)
 	}
 
 	// add untracked (optional)
@@ -280,9 +346,10 @@ func repairManifest(dir string, manifest *ManifestLock, issues []ManifestIssue,
 				return err
 			}
 			manifest.Migrations = append(manifest.Migrations, ManifestEntry{
-				Name:       name,
-				SQLSHA256:  hash,
-				CreatedUTC: time.Now().UTC().Format(time.RFC3339),
+				Name:            name,
+				SQLSHA256:       hash,
+				ChecksumVersion: manifestChecksumVersion(name),
+				CreatedUTC:      time.Now().UTC().Format(time.RFC3339),
 			})
 			seen[name] = true
 		}
@@ -296,18 +363,49 @@ func repairManifest(dir string, manifest *ManifestLock, issues []ManifestIssue,
 	return nil
 }
 
+// trackGoMigrations adds the Go migrations missing from manifest and reports
+// whether it changed. Entries already present keep their checksum, so an
+// edited migration still shows up as a hash mismatch.
+func trackGoMigrations(manifest *ManifestLock, gos []GoMigration) bool {
+	seen := make(map[string]bool, len(manifest.Migrations))
+	for _, e := range manifest.Migrations {
+		seen[e.Name] = true
+	}
+	changed := false
+	for _, m := range gos {
+		name := goManifestName(m.Version)
+		if seen[name] {
+			continue
+		}
+		manifest.Migrations = append(manifest.Migrations, ManifestEntry{
+			Name:       name,
+			SQLSHA256:  m.Checksum,
+			CreatedUTC: time.Now().UTC().Format(time.RFC3339),
+		})
+		changed = true
+	}
+	if !changed {
+		return false
+	}
+	sort.SliceStable(manifest.Migrations, func(i, j int) bool {
+		return manifest.Migrations[i].Name < manifest.Migrations[j].Name
+	})
+	manifest.Version++
+	return true
+}
+
 func appendMigrationToManifest(dir string, manifest *ManifestLock, baseName, createdUTC string) error {
 	name := normalizeManifestName(baseName)
-	path := filepath.Join(dir, name)
-	hash, err := hashMigrationFile(path)
+	hash, err := manifestEntryHash(dirSource(dir), name, packageChecksumAlgorithm())
 	if err != nil {
 		return err
 	}
 
 	manifest.Migrations = append(manifest.Migrations, ManifestEntry{
-		Name:       name,
-		SQLSHA256:  hash,
-		CreatedUTC: createdUTC,
+		Name:            name,
+		SQLSHA256:       hash,
+		ChecksumVersion: manifestChecksumVersion(name),
+		CreatedUTC:      createdUTC,
 	})
 
 	sort.SliceStable(manifest.Migrations, func(i, j int) bool {
@@ -322,6 +420,7 @@ func appendMigrationToManifest(dir string, manifest *ManifestLock, baseName, cre
 // --------------------
 
 // GenerateModelMigrations compares MODELS vs schema.lock.json and writes incremental migration files.
+// With opts.Engines it writes one tree per engine in lockstep; see GenerateOptions.Engines.
 // Default recommended usage:
 //   - CI:   ManifestStrict
 //   - Dev:  ManifestRepair + RepairAddUntracked=true (si quieres “adoptar” migraciones existentes)
@@ -333,160 +432,292 @@ func GenerateModelMigrations(models []interface{}, opts GenerateOptions) error {
 			dir = "migrations"
 		}
 	}
+
+	// 1) one tree per engine, all loaded before anything is written
+	var trees []*generateTree
+	if len(opts.Engines) == 0 {
+		engine := normalizeEngine(opts.Engine)
+		if engine == "" {
+			engine = normalizeEngine(os.Getenv("DB_TYPE"))
+		}
+		tree, err := loadGenerateTree(models, dir, engine, opts)
+		if err != nil {
+			return err
+		}
+		trees = append(trees, tree)
+	} else {
+		seen := map[string]bool{}
+		for _, e := range opts.Engines {
+			engine := normalizeEngine(e)
+			if engine == "" || seen[engine] {
+				return fmt.Errorf("invalid or duplicate engine %q", e)
+			}
+			seen[engine] = true
+			tree, err := loadGenerateTree(models, filepath.Join(dir, engine), engine, opts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", engine, err)
+			}
+			trees = append(trees, tree)
+		}
+	}
+
+	// Tables in stable order based on input models
+	first := trees[0]
+	tablesInOrder := orderTablesByFKDependencies(tablesFromModels(models, first.schemaMap), first.fkMap)
+
+	now := time.Now().UTC()
+	seq := 0
+	// new versions sort after every existing one in every tree
+	for _, tree := range trees {
+		for ts(now, seq) <= tree.latest {
+			seq++
+		}
+	}
+
+	// 2) every tree gets a migration, under the same name, for each table
+	// changed in any of them
+	for _, table := range tablesInOrder {
+		changes := make([]generateChange, len(trees))
+		kind := ""
+		for i, tree := range trees {
+			changes[i] = tree.diff(table)
+			switch {
+			case changes[i].kind == "create":
+				kind = "create"
+			case changes[i].kind == "alter" && kind == "":
+				kind = "alter"
+			}
+		}
+		if kind == "" {
+			continue
+		}
+		name := fmt.Sprintf("%s_%s_%s_table", ts(now, seq), kind, table)
+		seq++
+
+		written := false
+		for _, c := range changes {
+			written = written || strings.TrimSpace(c.up) != ""
+		}
+		if !written {
+			continue
+		}
+		for i, tree := range trees {
+			if err := tree.write(name, table, changes[i], now); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 3) save the snapshot and manifest of every tree that changed
+	for _, tree := range trees {
+		if err := tree.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateTree is one migration directory written by GenerateModelMigrations
+// for one engine, with its own manifest and schema.lock.json.
+type generateTree struct {
+	dir          string
+	engine       string
+	manifest     *ManifestLock
+	manifestPath string
+	snap         *SchemaSnapshot
+	lockPath     string
+
+	// model schema rendered for engine
+	schemaMap schemaInfo
+	orderMap  map[string][]string
+	defMap    map[string]tableInfo
+	fkMap     map[string][]foreignKeyInfo
+	idxMap    map[string][]IndexDefinition
+
+	latest        string // timestamp prefix of the newest existing version
+	changed       bool   // snapshot changed
+	newMigrations int
+}
+
+// generateChange is the migration of one table in one tree. An empty kind
+// means the table did not change there.
+type generateChange struct {
+	kind     string // "create" or "alter"
+	up, down string
+	table    SnapshotTable // snapshot after the change
+}
+
+// loadGenerateTree validates or repairs the manifest in dir, loads its
+// snapshot and renders the models for engine.
+func loadGenerateTree(models []interface{}, dir, engine string, opts GenerateOptions) (*generateTree, error) {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return err
+		return nil, err
+	}
+	t := &generateTree{
+		dir:          dir,
+		engine:       engine,
+		manifestPath: filepath.Join(dir, manifestFile),
+		lockPath:     filepath.Join(dir, "schema.lock.json"),
 	}
 
-	// 1) validate/repair manifest
-	manifestPath := filepath.Join(dir, "manifest.lock.json")
-	manifest, err := loadManifest(manifestPath)
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := checkGoMigrations(opts.GoMigrations); err != nil {
+		return nil, err
+	}
+	manifest, err := loadManifest(t.manifestPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	migrated, err := migrateManifest(dir, manifest)
+	migrated, err := migrateManifest(src, manifest)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if migrated {
-		if err := saveManifest(manifestPath, manifest); err != nil {
-			return err
+	upgraded, err := upgradeManifestChecksums(src, manifest)
+	if err != nil {
+		return nil, err
+	}
+	tracked := trackGoMigrations(manifest, opts.GoMigrations)
+	if migrated || upgraded || tracked {
+		if err := saveManifest(t.manifestPath, manifest); err != nil {
+			return nil, err
 		}
 	}
 
-	issues, err := validateManifest(dir, manifest)
+	issues, err := validateManifest(src, manifest)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if len(issues) > 0 {
+		reportManifestIssues(ObserverFunc(notify), issues)
 		if opts.ManifestMode == ManifestStrict {
 			first := issues[0]
-			return fmt.Errorf("manifest validation failed (%s): %s %s - %s",
+			return nil, fmt.Errorf("manifest validation failed (%s): %s %s - %s",
 				first.Type, first.Migration, first.File, first.Detail)
 		}
 
-		if err := repairManifest(dir, manifest, issues, opts.RepairAddUntracked); err != nil {
-			return err
+		if err := repairManifest(src, manifest, issues, opts.RepairAddUntracked); err != nil {
+			return nil, err
 		}
-		if err := saveManifest(manifestPath, manifest); err != nil {
-			return err
+		if err := saveManifest(t.manifestPath, manifest); err != nil {
+			return nil, err
 		}
 	}
+	t.manifest = manifest
 
-	// 2) load snapshot
-	lockPath := filepath.Join(dir, "schema.lock.json")
-	snap, err := loadSnapshot(lockPath)
+	files, err := readMigrationFiles(src)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if snap.Tables == nil {
-		snap.Tables = map[string]SnapshotTable{}
+	for _, f := range files {
+		v := migrationVersionFromFilename(f)
+		if len(v) < len(tsLayout) {
+			continue
+		}
+		if _, err := time.Parse(tsLayout, v[:len(tsLayout)]); err == nil {
+			t.latest = max(t.latest, v[:len(tsLayout)])
+		}
 	}
 
-	engine := normalizeEngine(opts.Engine)
-	engineForSQL := engine
-	if engineForSQL == "" {
-		engineForSQL = normalizeEngine(os.Getenv("DB_TYPE"))
+	snap, err := loadSnapshot(t.lockPath)
+	if err != nil {
+		return nil, err
+	}
+	if snap.Tables == nil {
+		snap.Tables = map[string]SnapshotTable{}
 	}
+	t.snap = snap
 
-	// 3) Build schema from models
-	schemaMap, orderMap, defMap, fkMap, idxMap, err := buildModelSchema(models, engineForSQL)
+	t.schemaMap, t.orderMap, t.defMap, t.fkMap, t.idxMap, err = buildModelSchema(models, engine)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return t, nil
+}
 
-	// Tables in stable order based on input models
-	tablesInOrder := orderTablesByFKDependencies(tablesFromModels(models, schemaMap), fkMap)
-
-	now := time.Now().UTC()
-	seq := 0
-	changed := false
-	newMigrations := 0
-
-	for _, table := range tablesInOrder {
-		modelCols := defMap[table] // col -> full definition
-		modelOrder := orderMap[table]
-		modelFKs := dedupeForeignKeys(fkMap[table])
-		modelIndexes := idxMap[table]
-
-		prev, exists := snap.Tables[table]
-		if !exists {
-			// CREATE TABLE migration
-			name := fmt.Sprintf("%s_create_%s_table", ts(now, seq), table)
-			seq++
-
-			up := createTableSQL(table, modelCols, modelOrder, modelFKs, engineForSQL)
-			up = appendIndexSQL(up, table, modelIndexes, engineForSQL)
-			down := fmt.Sprintf("DROP TABLE %s;", quoteIdent(engineForSQL, table))
-
-			if err := writeMigrationFile(dir, name, up, down); err != nil {
-				return err
-			}
-			if err := appendMigrationToManifest(dir, manifest, name, now.Format(time.RFC3339)); err != nil {
-				return err
-			}
-			newMigrations++
-
-			snap.Tables[table] = SnapshotTable{
-				Columns:     copyMap(modelCols),
-				Order:       append([]string{}, modelOrder...),
-				ForeignKeys: append([]foreignKeyInfo{}, modelFKs...),
-				Indexes:     cloneIndexes(modelIndexes),
-			}
-
-			changed = true
-			continue
-		}
-
-		added, removed, altered := diffSnapshot(prev.Columns, modelCols)
-		idxAdded, idxRemoved := diffIndexes(prev.Indexes, modelIndexes)
-		if len(added) == 0 && len(removed) == 0 && len(altered) == 0 && len(idxAdded) == 0 && len(idxRemoved) == 0 {
-			continue
-		}
-
-		// ALTER TABLE migration (one per table per run)
-		name := fmt.Sprintf("%s_alter_%s_table", ts(now, seq), table)
-		seq++
-
-		up, down := buildAlterSQL(quoteIdent(engineForSQL, table), prev.Columns, modelCols, modelOrder, added, removed, altered)
-		up, down = appendIndexChanges(up, down, table, idxAdded, idxRemoved, engineForSQL)
-		if strings.TrimSpace(up) == "" {
-			continue
-		}
+// diff compares table in the models with the snapshot.
+func (t *generateTree) diff(table string) generateChange {
+	modelCols := t.defMap[table] // col -> full definition
+	modelOrder := t.orderMap[table]
+	modelFKs := dedupeForeignKeys(t.fkMap[table])
+	modelIndexes := t.idxMap[table]
+	next := SnapshotTable{
+		Columns:     copyMap(modelCols),
+		Order:       append([]string{}, modelOrder...),
+		ForeignKeys: append([]foreignKeyInfo{}, modelFKs...),
+		Indexes:     cloneIndexes(modelIndexes),
+	}
+
+	prev, exists := t.snap.Tables[table]
+	if !exists {
+		// CREATE TABLE migration
+		up := createTableSQL(table, modelCols, modelOrder, modelFKs, t.engine)
+		up = appendIndexSQL(up, table, modelIndexes, t.engine)
+		down := fmt.Sprintf("DROP TABLE %s;", quoteIdent(t.engine, table))
+		return generateChange{kind: "create", up: up, down: down, table: next}
+	}
+
+	added, removed, altered := diffSnapshot(prev.Columns, modelCols)
+	idxAdded, idxRemoved := diffIndexes(prev.Indexes, modelIndexes)
+	if len(added) == 0 && len(removed) == 0 && len(altered) == 0 && len(idxAdded) == 0 && len(idxRemoved) == 0 {
+		return generateChange{}
+	}
+
+	// ALTER TABLE migration (one per table per run)
+	up, down := buildAlterSQL(quoteIdent(t.engine, table), prev.Columns, modelCols, modelOrder, added, removed, altered)
+	up, down = appendIndexChanges(up, down, table, idxAdded, idxRemoved, t.engine)
+	prev.Columns = next.Columns
+	prev.Order = next.Order
+	prev.ForeignKeys = next.ForeignKeys
+	prev.Indexes = next.Indexes
+	return generateChange{kind: "alter", up: up, down: down, table: prev}
+}
 
-		if err := writeMigrationFile(dir, name, up, down); err != nil {
-			return err
-		}
-		if err := appendMigrationToManifest(dir, manifest, name, now.Format(time.RFC3339)); err != nil {
+// write writes migration name for c and records it. A table unchanged in this
+// tree gets an empty migration, so every tree keeps the same versions.
+func (t *generateTree) write(name, table string, c generateChange, now time.Time) error {
+	path := filepath.Join(t.dir, name+".sql")
+	if strings.TrimSpace(c.up) == "" {
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("migration already exists (refusing to overwrite): %s", path)
+		}
+		content := fmt.Sprintf("-- No %s changes for %s; keeps the version in step with the other engines.\n\n%s",
+			table, t.engine, formatMigrationFile("", ""))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
 			return err
 		}
-		newMigrations++
-
-		// Update snapshot state for this table
-		prev.Columns = copyMap(modelCols)
-		prev.Order = append([]string{}, modelOrder...)
-		prev.ForeignKeys = append([]foreignKeyInfo{}, modelFKs...)
-		prev.Indexes = cloneIndexes(modelIndexes)
-		snap.Tables[table] = prev
+	} else if err := writeMigrationFile(t.dir, name, c.up, c.down); err != nil {
+		return err
+	}
+	notify(Event{Kind: EventMigrationWritten, Version: name, File: path})
+	if err := appendMigrationToManifest(t.dir, t.manifest, name, now.Format(time.RFC3339)); err != nil {
+		return err
+	}
+	t.newMigrations++
 
-		changed = true
+	if c.kind != "" {
+		t.snap.Tables[table] = c.table
+		t.changed = true
 	}
+	return nil
+}
 
-	// 4) Update snapshot only if something changed
-	if changed {
-		snap.Version++
-		if err := saveSnapshot(lockPath, snap); err != nil {
+// save writes the snapshot and the manifest if they changed.
+func (t *generateTree) save() error {
+	// Update snapshot only if something changed
+	if t.changed {
+		t.snap.Version++
+		if err := saveSnapshot(t.lockPath, t.snap); err != nil {
 			return err
 		}
 	}
 
-	// 5) Update manifest if new migrations were created
-	if newMigrations > 0 {
-		manifest.Version++
-		if err := saveManifest(manifestPath, manifest); err != nil {
+	// Update manifest if new migrations were created
+	if t.newMigrations > 0 {
+		t.manifest.Version++
+		if err := saveManifest(t.manifestPath, t.manifest); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
@@ -591,8 +822,11 @@ func orderTablesByFKDependencies(tables []string, fkMap map[string][]foreignKeyI
 	return ordered
 }
 
+// tsLayout is the timestamp prefix of generated migration versions.
+const tsLayout = "2006_01_02_150405"
+
 func ts(now time.Time, seq int) string {
-	return now.Add(time.Duration(seq) * time.Second).Format("2006_01_02_150405")
+	return now.Add(time.Duration(seq) * time.Second).Format(tsLayout)
 }
 
 func loadSnapshot(path string) (*SchemaSnapshot, error) {
@@ -0,0 +1,98 @@
+package driftflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type engineWidget struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:100"`
+}
+
+func (engineWidget) TableName() string { return "widgets" }
+
+type engineWidgetV2 struct {
+	ID    uint   `gorm:"primaryKey"`
+	Name  string `gorm:"size:100"`
+	Color string `gorm:"size:20"`
+}
+
+func (engineWidgetV2) TableName() string { return "widgets" }
+
+func treeFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := readMigrationFiles(dirSource(dir))
+	if err != nil {
+		t.Fatalf("readMigrationFiles: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	return names
+}
+
+func TestGenerateModelMigrationsEngines(t *testing.T) {
+	dir := t.TempDir()
+	opts := GenerateOptions{Dir: dir, ManifestMode: ManifestStrict, Engines: []string{"postgres", "sqlserver"}}
+	if err := GenerateModelMigrations([]interface{}{engineWidget{}}, opts); err != nil {
+		t.Fatalf("GenerateModelMigrations: %v", err)
+	}
+	if err := GenerateModelMigrations([]interface{}{engineWidgetV2{}}, opts); err != nil {
+		t.Fatalf("GenerateModelMigrations: %v", err)
+	}
+
+	pg := treeFiles(t, filepath.Join(dir, "postgres"))
+	ms := treeFiles(t, filepath.Join(dir, "sqlserver"))
+	if len(pg) != 2 || strings.Join(pg, ",") != strings.Join(ms, ",") {
+		t.Fatalf("expected the same two versions in both trees, got %v and %v", pg, ms)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "sqlserver", ms[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "CREATE TABLE [widgets]") {
+		t.Fatalf("expected SQL Server SQL, got:\n%s", b)
+	}
+	for _, engine := range []string{"postgres", "sqlserver"} {
+		if err := checkSource(dirSource(filepath.Join(dir, engine)), ObserverFunc(notify)); err != nil {
+			t.Fatalf("%s: %v", engine, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, engine, "schema.lock.json")); err != nil {
+			t.Fatalf("%s: expected its own snapshot: %v", engine, err)
+		}
+	}
+}
+
+func TestGenerateModelMigrationsNewEngineKeepsLockstep(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateModelMigrations([]interface{}{engineWidget{}}, GenerateOptions{Dir: dir, Engines: []string{"postgres"}}); err != nil {
+		t.Fatalf("GenerateModelMigrations: %v", err)
+	}
+	if err := GenerateModelMigrations([]interface{}{engineWidget{}}, GenerateOptions{Dir: dir, Engines: []string{"postgres", "mysql"}}); err != nil {
+		t.Fatalf("GenerateModelMigrations: %v", err)
+	}
+
+	pg := treeFiles(t, filepath.Join(dir, "postgres"))
+	my := treeFiles(t, filepath.Join(dir, "mysql"))
+	if len(pg) != 2 || len(my) != 1 || pg[1] != my[0] {
+		t.Fatalf("expected the new create in both trees, got %v and %v", pg, my)
+	}
+	script, _, err := readMigrationScript(dirSource(filepath.Join(dir, "postgres")), filepath.Join(dir, "postgres", pg[1]), ChecksumNormalized)
+	if err != nil || script.Up != "" || script.Down != "" {
+		t.Fatalf("expected an empty migration in the unchanged tree, got %+v, %v", script, err)
+	}
+	if err := checkSource(dirSource(filepath.Join(dir, "postgres")), ObserverFunc(notify)); err != nil {
+		t.Fatalf("postgres: %v", err)
+	}
+}
+
+func TestGenerateModelMigrationsRejectsDuplicateEngines(t *testing.T) {
+	err := GenerateModelMigrations([]interface{}{engineWidget{}}, GenerateOptions{Dir: t.TempDir(), Engines: []string{"postgres", "Postgres"}})
+	if err == nil || !strings.Contains(err.Error(), "duplicate engine") {
+		t.Fatalf("expected duplicate engine error, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package driftflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GoMigration is a migration written in Go, for changes SQL cannot express
+// such as backfills or re-encrypting columns. It belongs to the migration set
+// it is passed with (MigrateOptions.GoMigrations), is ordered by Version
+// together with the .sql files of that set and is recorded in
+// migrations_history and manifest.lock.json like them.
+type GoMigration struct {
+	// Version sorts against the .sql file names, e.g.
+	// "2025_03_01_120000_backfill_user_emails".
+	Version string
+	// Checksum identifies the current code of Up/Down. Change it whenever the
+	// migration is edited; applied migrations whose checksum changes are
+	// reported as modified, like edited .sql files.
+	Checksum string
+	Up       func(tx *gorm.DB) error
+	Down     func(tx *gorm.DB) error
+	// NoTransaction runs Up and Down outside a transaction.
+	NoTransaction bool
+	// DependsOn lists the versions that must be applied before this one,
+	// like "-- +migrate DependsOn:" in a .sql file.
+	DependsOn []string
+}
+
+// check reports why m cannot run.
+func (m GoMigration) check() error {
+	if m.Version == "" {
+		return fmt.Errorf("go migration with empty version")
+	}
+	if m.Checksum == "" || len(m.Checksum) > 64 {
+		return fmt.Errorf("go migration %s needs a checksum of 1 to 64 characters", m.Version)
+	}
+	if m.Up == nil {
+		return fmt.Errorf("go migration %s has no Up function", m.Version)
+	}
+	return nil
+}
+
+// checkGoMigrations fails on an invalid or twice listed Go migration.
+func checkGoMigrations(gos []GoMigration) error {
+	seen := make(map[string]bool, len(gos))
+	for _, m := range gos {
+		if err := m.check(); err != nil {
+			return err
+		}
+		if seen[m.Version] {
+			return fmt.Errorf("go migration %s listed twice", m.Version)
+		}
+		seen[m.Version] = true
+	}
+	return nil
+}
+
+// goMigrationMap indexes gos by version.
+func goMigrationMap(gos []GoMigration) map[string]GoMigration {
+	out := make(map[string]GoMigration, len(gos))
+	for _, m := range gos {
+		out[m.Version] = m
+	}
+	return out
+}
+
+// goManifestSuffix marks Go migrations in manifest.lock.json, whose entries
+// are named "<version>.go" and hash to their code-supplied checksum.
+const goManifestSuffix = ".go"
+
+func goManifestName(version string) string {
+	return version + goManifestSuffix
+}
+
+// manifestEntryVersion returns the migration version of a manifest entry name.
+func manifestEntryVersion(name string) string {
+	return strings.TrimSuffix(migrationVersionFromFilename(name), goManifestSuffix)
+}
+
+// mergeGoVersions adds the Go migration versions to the file
+// versions and returns them in run order.
+func mergeGoVersions(versions []string, gos map[string]GoMigration) []string {
+	if len(gos) == 0 {
+		return versions
+	}
+	merged := append([]string{}, versions...)
+	for v := range gos {
+		merged = append(merged, v)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i] < merged[j] })
+	return merged
+}
@@ -0,0 +1,156 @@
+package driftflow
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func backfillMigration(calls *[]string) GoMigration {
+	return GoMigration{
+		Version:  "002a_backfill_posts",
+		Checksum: "v1",
+		Up:       func(tx *gorm.DB) error { *calls = append(*calls, "up"); return nil },
+		Down:     func(tx *gorm.DB) error { *calls = append(*calls, "down"); return nil },
+	}
+}
+
+func TestCheckGoMigrationsRejectsInvalid(t *testing.T) {
+	var calls []string
+	cases := map[string][]GoMigration{
+		"duplicate":   {backfillMigration(&calls), backfillMigration(&calls)},
+		"no version":  {{Checksum: "v1", Up: func(*gorm.DB) error { return nil }}},
+		"no checksum": {{Version: "004_x", Up: func(*gorm.DB) error { return nil }}},
+		"no up":       {{Version: "004_x", Checksum: "v1"}},
+	}
+	for name, gos := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := dirSource(t.TempDir()).withGoMigrations(gos).validate(); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestPlanInterleavesGoMigrations(t *testing.T) {
+	var calls []string
+	src := dirSource(writePlanFixtures(t)).withGoMigrations([]GoMigration{backfillMigration(&calls)})
+
+	plan, err := newSourceState(t, src, "001_users").planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	var got []string
+	for _, step := range plan {
+		got = append(got, step.Version)
+	}
+	if strings.Join(got, ",") != "002_posts,002a_backfill_posts,003_tags" {
+		t.Fatalf("unexpected order: %v", got)
+	}
+	step := plan[1]
+	if !step.Go || step.Checksum != "v1" || step.SQL != "" || step.Batch != 2 {
+		t.Fatalf("unexpected go step: %+v", step)
+	}
+	if err := runStep(nil, step, MigrateOptions{}); err != nil {
+		t.Fatalf("runStep: %v", err)
+	}
+	if strings.Join(calls, ",") != "up" {
+		t.Fatalf("expected Up to run, got %v", calls)
+	}
+
+	// another migration set in the same process does not see it
+	plan, err = newTestState(t, writePlanFixtures(t), "001_users").planUp()
+	if err != nil || len(plan) != 2 {
+		t.Fatalf("expected only the .sql migrations, got %+v, %v", plan, err)
+	}
+}
+
+func TestPlanDownStepsRevertsGoMigration(t *testing.T) {
+	var calls []string
+	src := dirSource(writePlanFixtures(t)).withGoMigrations([]GoMigration{backfillMigration(&calls)})
+	s := newSourceState(t, src, "001_users", "002_posts", "002a_backfill_posts")
+
+	plan, err := s.planDownSteps(1)
+	if err != nil {
+		t.Fatalf("planDownSteps: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Version != "002a_backfill_posts" || plan[0].Direction != DirectionDown {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if err := runStep(nil, plan[0], MigrateOptions{}); err != nil || strings.Join(calls, ",") != "down" {
+		t.Fatalf("expected Down to run, got %v (%v)", calls, err)
+	}
+
+	m := s.applied["002a_backfill_posts"]
+	m.Checksum = "v0"
+	s.applied["002a_backfill_posts"] = m
+	if _, err := s.planDownSteps(1); err == nil || !strings.Contains(err.Error(), "modified after applied") {
+		t.Fatalf("expected modified error, got %v", err)
+	}
+}
+
+func TestValidateManifestTracksGoMigrations(t *testing.T) {
+	var calls []string
+	dir := t.TempDir()
+	src := dirSource(dir).withGoMigrations([]GoMigration{backfillMigration(&calls)})
+
+	issues, err := validateManifest(src, &ManifestLock{})
+	if err != nil {
+		t.Fatalf("validateManifest: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Type != IssueUntracked || issues[0].Migration != "002a_backfill_posts.go" {
+		t.Fatalf("expected untracked go migration, got %+v", issues)
+	}
+
+	manifest := &ManifestLock{Migrations: []ManifestEntry{
+		{Name: "002a_backfill_posts.go", SQLSHA256: "v0"},
+		{Name: "009_gone.go", SQLSHA256: "v1"},
+	}}
+	issues, err = validateManifest(src, manifest)
+	if err != nil {
+		t.Fatalf("validateManifest: %v", err)
+	}
+	found := map[string]ManifestIssueType{}
+	for _, is := range issues {
+		found[is.Migration] = is.Type
+	}
+	if found["002a_backfill_posts.go"] != IssueHashMismatch || found["009_gone.go"] != IssueMissingPair {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+
+	// a run without the Go code, like the stock CLI, leaves the entries alone
+	if issues, err := validateManifest(dirSource(dir), manifest); err != nil || len(issues) != 0 {
+		t.Fatalf("expected no issues without Go migrations, got %+v, %v", issues, err)
+	}
+
+	if err := repairManifest(src, manifest, issues, false); err != nil {
+		t.Fatalf("repairManifest: %v", err)
+	}
+	if manifest.Migrations[0].SQLSHA256 != "v1" {
+		t.Fatalf("expected checksum to be re-signed, got %+v", manifest.Migrations[0])
+	}
+}
+
+func TestGenerateRecordsGoMigrations(t *testing.T) {
+	var calls []string
+	dir := t.TempDir()
+	gos := []GoMigration{backfillMigration(&calls)}
+	if err := GenerateModelMigrations(nil, GenerateOptions{Dir: dir, Engine: "postgres", ManifestMode: ManifestStrict, GoMigrations: gos}); err != nil {
+		t.Fatalf("GenerateModelMigrations: %v", err)
+	}
+	manifest, err := loadManifest(filepath.Join(dir, manifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Migrations) != 1 || manifest.Migrations[0].Name != "002a_backfill_posts.go" || manifest.Migrations[0].SQLSHA256 != "v1" {
+		t.Fatalf("expected the Go migration in the manifest, got %+v", manifest.Migrations)
+	}
+	if err := checkSource(dirSource(dir).withGoMigrations(gos), ObserverFunc(notify)); err != nil {
+		t.Fatalf("expected a consistent manifest, got %v", err)
+	}
+	if err := checkSource(dirSource(dir), ObserverFunc(notify)); err != nil {
+		t.Fatalf("expected the Go entry to be skipped without its code, got %v", err)
+	}
+}
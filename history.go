@@ -0,0 +1,156 @@
+package driftflow
+
+import (
+	"errors"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const modulePath = "github.com/misaelcrespo30/DriftFlow"
+
+// HistoryStatus tells how a migrations_history row came to be.
+type HistoryStatus string
+
+const (
+	HistoryStatusApplied  HistoryStatus = "applied"
+	HistoryStatusBaseline HistoryStatus = "baseline"
+	// HistoryStatusMarked rows were recorded by MarkApplied or ForceVersion
+	// without running the migration.
+	HistoryStatusMarked HistoryStatus = "marked"
+	// HistoryStatusSquashed rows stand for the rows of the migrations a
+	// squashed baseline replaced; see Squash.
+	HistoryStatusSquashed HistoryStatus = "squashed"
+)
+
+// MigrationFailure records a failed attempt to apply or revert a migration.
+// The attempt itself was rolled back (unless it ran with notransaction), so
+// these rows are the only trace of it.
+type MigrationFailure struct {
+	ID               uint               `gorm:"primaryKey" json:"id"`
+	Version          string             `gorm:"size:255;index" json:"version"`
+	Direction        MigrationDirection `gorm:"size:8" json:"direction"`
+	Batch            int                `json:"batch"`
+	Checksum         string             `gorm:"size:64" json:"checksum"`
+	Error            string             `gorm:"type:text" json:"error"`
+	Statement        string             `gorm:"type:text" json:"statement,omitempty"`
+	StatementIndex   int                `json:"statement_index,omitempty"`
+	DurationMs       int64              `json:"duration_ms"`
+	DBUser           string             `gorm:"size:128" json:"db_user,omitempty"`
+	Host             string             `gorm:"size:255" json:"host,omitempty"`
+	DriftflowVersion string             `gorm:"size:64" json:"driftflow_version,omitempty"`
+	FailedAt         time.Time          `gorm:"autoCreateTime" json:"failed_at"`
+}
+
+func (MigrationFailure) TableName() string {
+	return "migrations_history_failures"
+}
+
+// ListMigrationFailures returns the recorded failed attempts, newest first.
+func ListMigrationFailures(db *gorm.DB) ([]MigrationFailure, error) {
+	var rows []MigrationFailure
+	if err := db.Table(packageTables().failuresTable()).Order("id desc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// runInfo identifies who ran a migration, for history and failure rows.
+type runInfo struct {
+	dbUser  string
+	host    string
+	version string
+}
+
+// loadRunInfo reads the database user of the session. A failed lookup is
+// reported to obs and leaves the user empty.
+func loadRunInfo(db *gorm.DB, obs Observer) runInfo {
+	info := runInfo{version: driftflowVersion()}
+	info.host, _ = os.Hostname()
+
+	var query string
+	switch strings.ToLower(db.Dialector.Name()) {
+	case "postgres":
+		query = "SELECT current_user"
+	case "mysql":
+		query = "SELECT CURRENT_USER()"
+	case "sqlserver":
+		query = "SELECT SUSER_SNAME()"
+	default:
+		return info
+	}
+	if err := db.Raw(query).Scan(&info.dbUser).Error; err != nil {
+		obs.OnEvent(Event{Kind: EventNotice, Message: "could not read database user", Err: err})
+	}
+	return info
+}
+
+// historyRow builds the migrations_history row for a successful step.
+func (r runInfo) historyRow(step PlannedMigration, duration time.Duration) SchemaMigration {
+	return SchemaMigration{
+		Version:          step.Version,
+		Batch:            step.Batch,
+		Checksum:         step.Checksum,
+		ChecksumVersion:  step.algorithm,
+		AppliedAt:        time.Now().UTC(),
+		DurationMs:       duration.Milliseconds(),
+		DBUser:           r.dbUser,
+		Host:             r.host,
+		DriftflowVersion: r.version,
+		Status:           HistoryStatusApplied,
+	}
+}
+
+// failureRow builds the failure record for step, taking the failing
+// statement from a *StatementError in err.
+func (r runInfo) failureRow(step PlannedMigration, duration time.Duration, err error) MigrationFailure {
+	f := MigrationFailure{
+		Version:          step.Version,
+		Direction:        step.Direction,
+		Batch:            step.Batch,
+		Checksum:         step.Checksum,
+		Error:            err.Error(),
+		DurationMs:       duration.Milliseconds(),
+		DBUser:           r.dbUser,
+		Host:             r.host,
+		DriftflowVersion: r.version,
+	}
+	var stmtErr *StatementError
+	if errors.As(err, &stmtErr) {
+		f.Statement = stmtErr.SQL
+		f.StatementIndex = stmtErr.Index
+	}
+	return f
+}
+
+var (
+	buildVersionOnce      sync.Once
+	driftflowBuildVersion string
+)
+
+// driftflowVersion returns the module version DriftFlow was built from, or
+// "(devel)" for local builds.
+func driftflowVersion() string {
+	buildVersionOnce.Do(func() {
+		driftflowBuildVersion = "(devel)"
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		if bi.Main.Path == modulePath && bi.Main.Version != "" {
+			driftflowBuildVersion = bi.Main.Version
+			return
+		}
+		for _, dep := range bi.Deps {
+			if dep.Path == modulePath {
+				driftflowBuildVersion = dep.Version
+				return
+			}
+		}
+	})
+	return driftflowBuildVersion
+}
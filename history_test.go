@@ -0,0 +1,45 @@
+package driftflow
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFailureRowTakesFailingStatement(t *testing.T) {
+	run := runInfo{dbUser: "deploy", host: "ci-1", version: "v1.2.3"}
+	step := PlannedMigration{Version: "002_posts", Direction: DirectionUp, Batch: 3, Checksum: "abc"}
+	err := fmt.Errorf("apply 002_posts.sql: %w", &StatementError{Index: 2, SQL: "ALTER TABLE posts ADD x int;", Err: errors.New("lock timeout")})
+
+	f := run.failureRow(step, 1500*time.Millisecond, err)
+	if f.Statement != "ALTER TABLE posts ADD x int;" || f.StatementIndex != 2 {
+		t.Fatalf("expected failing statement, got %+v", f)
+	}
+	if f.Version != "002_posts" || f.Direction != DirectionUp || f.Batch != 3 || f.DurationMs != 1500 ||
+		f.DBUser != "deploy" || f.Host != "ci-1" || f.DriftflowVersion != "v1.2.3" || f.Error != err.Error() {
+		t.Fatalf("unexpected failure row: %+v", f)
+	}
+
+	f = run.failureRow(step, 0, errors.New("hook failed"))
+	if f.Statement != "" || f.StatementIndex != 0 {
+		t.Fatalf("expected no statement, got %+v", f)
+	}
+}
+
+func TestHistoryRow(t *testing.T) {
+	run := runInfo{dbUser: "deploy", host: "ci-1", version: "v1.2.3"}
+	step := PlannedMigration{Version: "001_users", Batch: 2, Checksum: "abc"}
+
+	rec := run.historyRow(step, 250*time.Millisecond)
+	if rec.Version != "001_users" || rec.Batch != 2 || rec.Checksum != "abc" || rec.DurationMs != 250 ||
+		rec.Status != HistoryStatusApplied || rec.DBUser != "deploy" || rec.Host != "ci-1" || rec.DriftflowVersion != "v1.2.3" {
+		t.Fatalf("unexpected history row: %+v", rec)
+	}
+}
+
+func TestDriftflowVersion(t *testing.T) {
+	if driftflowVersion() == "" {
+		t.Fatalf("expected a version")
+	}
+}
@@ -0,0 +1,91 @@
+package driftflow
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"gorm.io/gorm"
+)
+
+// MigrationHooks is called by Up, MigrateTo, DownSteps and RollbackBatch around
+// the steps they execute. BeforeEach and AfterEach run inside the step's
+// transaction, so an error from either rolls the step back. None of the hooks
+// is called when there is nothing to run. Embed NopHooks to implement only
+// some of them.
+type MigrationHooks interface {
+	BeforeAll(db *gorm.DB, plan []PlannedMigration) error
+	BeforeEach(tx *gorm.DB, step PlannedMigration) error
+	AfterEach(tx *gorm.DB, step PlannedMigration) error
+	AfterAll(db *gorm.DB, plan []PlannedMigration) error
+	// OnError receives the step that failed (zero for BeforeAll and AfterAll
+	// failures) and the error the run is about to return.
+	OnError(db *gorm.DB, step PlannedMigration, err error)
+}
+
+// NopHooks implements MigrationHooks with no-ops.
+type NopHooks struct{}
+
+func (NopHooks) BeforeAll(*gorm.DB, []PlannedMigration) error { return nil }
+func (NopHooks) BeforeEach(*gorm.DB, PlannedMigration) error  { return nil }
+func (NopHooks) AfterEach(*gorm.DB, PlannedMigration) error   { return nil }
+func (NopHooks) AfterAll(*gorm.DB, []PlannedMigration) error  { return nil }
+func (NopHooks) OnError(*gorm.DB, PlannedMigration, error)    {}
+
+// Callback files are plain SQL files in the migrations directory that run
+// around every run with steps to execute. They are not migrations: they are
+// neither versioned nor recorded in migrations_history or the manifest.
+const (
+	beforeMigrateCallback    = "beforeMigrate.sql"
+	afterEachMigrateCallback = "afterEachMigrate.sql"
+	afterMigrateCallback     = "afterMigrate.sql"
+)
+
+func isCallbackFile(name string) bool {
+	switch filepath.Base(name) {
+	case beforeMigrateCallback, afterEachMigrateCallback, afterMigrateCallback:
+		return true
+	}
+	return false
+}
+
+// migrationCallbacks holds the statements of the callback files in a
+// migrations directory.
+type migrationCallbacks struct {
+	beforeAll []string
+	afterEach []string
+	afterAll  []string
+}
+
+func loadMigrationCallbacks(src migrationSource, dialect string) (migrationCallbacks, error) {
+	var cb migrationCallbacks
+	for name, dst := range map[string]*[]string{
+		beforeMigrateCallback:    &cb.beforeAll,
+		afterEachMigrateCallback: &cb.afterEach,
+		afterMigrateCallback:     &cb.afterAll,
+	} {
+		path := src.path(name)
+		b, err := src.readFile(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return cb, err
+		}
+		stmts, err := splitSQLStatements(string(b), dialect)
+		if err != nil {
+			return cb, fmt.Errorf("%s: %w", path, err)
+		}
+		*dst = stmts
+	}
+	return cb, nil
+}
+
+// hooks returns the configured hooks or NopHooks.
+func (o MigrateOptions) hooks() MigrationHooks {
+	if o.Hooks == nil {
+		return NopHooks{}
+	}
+	return o.Hooks
+}
@@ -0,0 +1,78 @@
+package driftflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type recordingHooks struct {
+	NopHooks
+	calls      []string
+	beforeEach error
+}
+
+func (h *recordingHooks) BeforeEach(_ *gorm.DB, step PlannedMigration) error {
+	h.calls = append(h.calls, "before "+step.Version)
+	return h.beforeEach
+}
+
+func (h *recordingHooks) AfterEach(_ *gorm.DB, step PlannedMigration) error {
+	h.calls = append(h.calls, "after "+step.Version)
+	return nil
+}
+
+func TestRunStepWithHooksOrder(t *testing.T) {
+	hooks := &recordingHooks{}
+	step := PlannedMigration{Version: "001_x", Direction: DirectionUp, Go: true, run: func(*gorm.DB) error {
+		hooks.calls = append(hooks.calls, "run")
+		return nil
+	}}
+	s := &migrationState{}
+	if err := s.runStepWithHooks(nil, step, hooks); err != nil {
+		t.Fatalf("runStepWithHooks: %v", err)
+	}
+	if want := []string{"before 001_x", "run", "after 001_x"}; !reflect.DeepEqual(hooks.calls, want) {
+		t.Fatalf("got %v, want %v", hooks.calls, want)
+	}
+
+	hooks.calls = nil
+	hooks.beforeEach = errors.New("not now")
+	if err := s.runStepWithHooks(nil, step, hooks); err == nil || len(hooks.calls) != 1 {
+		t.Fatalf("expected BeforeEach to abort the step, got %v (%v)", hooks.calls, err)
+	}
+}
+
+func TestCallbackFilesAreNotMigrations(t *testing.T) {
+	dir := writePlanFixtures(t)
+	if err := os.WriteFile(filepath.Join(dir, afterMigrateCallback), []byte("REFRESH MATERIALIZED VIEW stats;\nGRANT SELECT ON stats TO app;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := readMigrationFiles(dirSource(dir))
+	if err != nil {
+		t.Fatalf("readMigrationFiles: %v", err)
+	}
+	for _, f := range files {
+		if isCallbackFile(f) {
+			t.Fatalf("callback file listed as migration: %s", f)
+		}
+	}
+	if err := Validate(dir); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	cb, err := loadMigrationCallbacks(dirSource(dir), "postgres")
+	if err != nil {
+		t.Fatalf("loadMigrationCallbacks: %v", err)
+	}
+	if len(cb.beforeAll) != 0 || len(cb.afterEach) != 0 || len(cb.afterAll) != 2 ||
+		!strings.HasPrefix(cb.afterAll[0], "REFRESH MATERIALIZED VIEW") {
+		t.Fatalf("unexpected callbacks: %+v", cb)
+	}
+}
@@ -0,0 +1,253 @@
+package driftflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLocked is returned when another process holds the migration lock and it
+// could not be acquired before the wait timeout expired.
+var ErrLocked = errors.New("migration lock is held by another process")
+
+// DefaultLockWaitTimeout is used when MigrateOptions.LockWaitTimeout is zero.
+const DefaultLockWaitTimeout = 2 * time.Minute
+
+// StaleLockTimeout is how long a driftflow_lock row may go without being
+// refreshed by its holder before another process removes it as left behind by
+// a crashed run. The holder refreshes it several times within this period.
+const StaleLockTimeout = 5 * time.Minute
+
+const (
+	migrationLockName    = "driftflow_migrations"
+	migrationLockPolling = 500 * time.Millisecond
+	tableLockRefresh     = StaleLockTimeout / 5
+)
+
+// MigrationLock is the row used by the lock-table fallback on engines without
+// a native advisory lock.
+type MigrationLock struct {
+	Name     string    `gorm:"primaryKey;size:128" json:"name"`
+	Owner    string    `gorm:"size:255" json:"owner"`
+	LockedAt time.Time `json:"locked_at"`
+}
+
+func (MigrationLock) TableName() string {
+	return "driftflow_lock"
+}
+
+// withMigrationLock runs fn while holding the cross-process migration lock.
+// Postgres, MySQL and SQL Server use session-level locks pinned to a single
+// connection, which fn receives so the run does not need a second one; other
+// engines fall back to the driftflow_lock table and fn receives db.
+func withMigrationLock(db *gorm.DB, opts MigrateOptions, fn func(db *gorm.DB) error) error {
+	if opts.DisableLock {
+		return fn(db)
+	}
+	timeout := opts.LockWaitTimeout
+	if timeout <= 0 {
+		timeout = DefaultLockWaitTimeout
+	}
+	tables := opts.tables()
+	name := tables.lockName()
+	if opts.tenant != "" {
+		name += ":" + opts.tenant
+	}
+
+	switch strings.ToLower(db.Dialector.Name()) {
+	case "postgres":
+		return withConn(db, func(conn *gorm.DB) error {
+			return runLocked(func() error { return fn(conn) },
+				func() error { return acquirePostgresLock(conn, name, timeout) },
+				func() error {
+					return uncancelled(conn).Exec("SELECT pg_advisory_unlock(?)", migrationLockKey(name)).Error
+				},
+			)
+		})
+	case "mysql":
+		return withConn(db, func(conn *gorm.DB) error {
+			return runLocked(func() error { return fn(conn) },
+				func() error { return acquireMySQLLock(conn, name, timeout) },
+				func() error { return uncancelled(conn).Exec("SELECT RELEASE_LOCK(?)", name).Error },
+			)
+		})
+	case "sqlserver":
+		return withConn(db, func(conn *gorm.DB) error {
+			return runLocked(func() error { return fn(conn) },
+				func() error { return acquireMSSQLLock(conn, name, timeout) },
+				func() error {
+					return uncancelled(conn).Exec("EXEC sp_releaseapplock @Resource = ?, @LockOwner = 'Session'", name).Error
+				},
+			)
+		})
+	default:
+		owner := lockOwner()
+		table := tables.lockTable()
+		return runLocked(
+			func() error {
+				stop := refreshTableLock(db, table, name, owner)
+				defer stop()
+				return fn(db)
+			},
+			func() error { return acquireTableLock(db, table, name, owner, timeout) },
+			func() error {
+				return uncancelled(db).Table(table).Where("name = ? AND owner = ?", name, owner).Delete(&MigrationLock{}).Error
+			},
+		)
+	}
+}
+
+// uncancelled returns db detached from the cancellation of its context, for
+// statements that must run after a cancelled run: releasing the lock and
+// restoring the session before the connection goes back to the pool.
+func uncancelled(db *gorm.DB) *gorm.DB {
+	return db.WithContext(context.WithoutCancel(dbContext(db)))
+}
+
+func runLocked(fn func() error, acquire func() error, release func() error) (err error) {
+	if err := acquire(); err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := release(); rerr != nil && err == nil {
+			err = fmt.Errorf("release migration lock: %w", rerr)
+		}
+	}()
+	return fn()
+}
+
+// migrationLockKey maps a lock name to the bigint key used by Postgres
+// advisory locks.
+func migrationLockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64() & math.MaxInt64)
+}
+
+func acquirePostgresLock(conn *gorm.DB, name string, timeout time.Duration) error {
+	key := migrationLockKey(name)
+	deadline := time.Now().Add(timeout)
+	for {
+		var ok bool
+		if err := conn.Raw("SELECT pg_try_advisory_lock(?)", key).Scan(&ok).Error; err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w (waited %s)", ErrLocked, timeout)
+		}
+		if err := sleepContext(dbContext(conn), migrationLockPolling); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+	}
+}
+
+func acquireMySQLLock(conn *gorm.DB, name string, timeout time.Duration) error {
+	var result sql.NullInt64
+	seconds := int(math.Ceil(timeout.Seconds()))
+	if err := conn.Raw("SELECT GET_LOCK(?, ?)", name, seconds).Scan(&result).Error; err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if !result.Valid {
+		return fmt.Errorf("acquire migration lock: GET_LOCK returned NULL")
+	}
+	if result.Int64 != 1 {
+		return fmt.Errorf("%w (waited %s)", ErrLocked, timeout)
+	}
+	return nil
+}
+
+func acquireMSSQLLock(conn *gorm.DB, name string, timeout time.Duration) error {
+	var result int
+	err := conn.Raw(`
+DECLARE @result int;
+EXEC @result = sp_getapplock @Resource = ?, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = ?;
+SELECT @result;
+`, name, timeout.Milliseconds()).Scan(&result).Error
+	if err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	switch {
+	case result >= 0:
+		return nil
+	case result == -1:
+		return fmt.Errorf("%w (waited %s)", ErrLocked, timeout)
+	default:
+		return fmt.Errorf("acquire migration lock: sp_getapplock returned %d", result)
+	}
+}
+
+func acquireTableLock(db *gorm.DB, table, name, owner string, timeout time.Duration) error {
+	if err := db.Table(table).AutoMigrate(&MigrationLock{}); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := expireTableLock(db, table, name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		row := MigrationLock{Name: name, Owner: owner, LockedAt: time.Now().UTC()}
+		res := db.Table(table).Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		if res.Error != nil {
+			return fmt.Errorf("acquire migration lock: %w", res.Error)
+		}
+		if res.RowsAffected == 1 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w (waited %s)", ErrLocked, timeout)
+		}
+		if err := sleepContext(dbContext(db), migrationLockPolling); err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+	}
+}
+
+// expireTableLock removes the lock row name if its holder has not refreshed
+// it within StaleLockTimeout of now.
+func expireTableLock(db *gorm.DB, table, name string, now time.Time) error {
+	return db.Table(table).Where("name = ? AND locked_at < ?", name, now.Add(-StaleLockTimeout)).Delete(&MigrationLock{}).Error
+}
+
+// refreshTableLock keeps LockedAt of the held lock row current until the
+// returned stop is called, so a long run is not taken for a crashed one.
+func refreshTableLock(db *gorm.DB, table, name, owner string) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(tableLockRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// a failed refresh is retried on the next tick
+				db.Table(table).Where("name = ? AND owner = ?", name, owner).Update("locked_at", time.Now().UTC())
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
@@ -0,0 +1,222 @@
+package driftflow
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMigrationLockKeyStable(t *testing.T) {
+	a := migrationLockKey("driftflow_migrations")
+	b := migrationLockKey("driftflow_migrations")
+	if a != b {
+		t.Fatalf("expected stable key, got %d and %d", a, b)
+	}
+	if a < 0 {
+		t.Fatalf("expected non-negative key, got %d", a)
+	}
+	if migrationLockKey("other") == a {
+		t.Fatalf("expected different names to map to different keys")
+	}
+}
+
+func TestRunLockedReleasesOnError(t *testing.T) {
+	released := false
+	runErr := errors.New("boom")
+	err := runLocked(
+		func() error { return runErr },
+		func() error { return nil },
+		func() error { released = true; return nil },
+	)
+	if !errors.Is(err, runErr) {
+		t.Fatalf("expected run error, got %v", err)
+	}
+	if !released {
+		t.Fatalf("expected lock to be released")
+	}
+}
+
+func TestRunLockedSkipsWhenNotAcquired(t *testing.T) {
+	ran := false
+	err := runLocked(
+		func() error { ran = true; return nil },
+		func() error { return ErrLocked },
+		func() error { t.Fatalf("release must not run"); return nil },
+	)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if ran {
+		t.Fatalf("fn must not run without the lock")
+	}
+}
+
+// fakeLockServer is a database/sql driver emulating the session-level lock
+// and session settings of a Postgres server on which every table exists:
+// statements fail once their context is cancelled, like on a real
+// connection.
+type fakeLockServer struct {
+	mu       sync.Mutex
+	held     bool
+	execs    []string
+	lastArgs []driver.NamedValue
+}
+
+func (s *fakeLockServer) Connect(context.Context) (driver.Conn, error) { return fakeLockConn{s}, nil }
+func (s *fakeLockServer) Driver() driver.Driver                        { return nil }
+
+func (s *fakeLockServer) executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.execs...)
+}
+
+type fakeLockConn struct{ s *fakeLockServer }
+
+func (c fakeLockConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeLockConn) Close() error                        { return nil }
+func (c fakeLockConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c fakeLockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	c.s.execs = append(c.s.execs, query)
+	c.s.lastArgs = args
+	if strings.Contains(query, "pg_advisory_unlock") {
+		c.s.held = false
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c fakeLockConn) QueryContext(ctx context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if strings.Contains(query, "information_schema.tables") {
+		return &fakeRows{value: int64(1)}, nil // every table exists
+	}
+	if !strings.Contains(query, "pg_try_advisory_lock") {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+	ok := !c.s.held
+	c.s.held = true
+	return &fakeRows{value: ok}, nil
+}
+
+type fakeRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func fakeLockDB(t *testing.T) (*gorm.DB, *fakeLockServer) {
+	t.Helper()
+	server := &fakeLockServer{}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(server)}), &gorm.Config{DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db, server
+}
+
+func TestMigrationLockReleasedAfterCancel(t *testing.T) {
+	db, server := fakeLockDB(t)
+	opts := MigrateOptions{LockWaitTimeout: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := withMigrationLock(db.WithContext(ctx), opts, func(*gorm.DB) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || strings.Contains(err.Error(), "release") {
+		t.Fatalf("expected the cancellation error alone, got %v", err)
+	}
+
+	if err := withMigrationLock(db, opts, func(*gorm.DB) error { return nil }); err != nil {
+		t.Fatalf("expected the lock to be free after a cancelled run, got %v", err)
+	}
+	if server.held {
+		t.Fatalf("expected the lock to be released")
+	}
+}
+
+func TestMigrationLockSharesItsConnection(t *testing.T) {
+	db, server := fakeLockDB(t)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	// a second connection would wait for the first until the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	opts := MigrateOptions{LockTimeout: time.Second}
+	err = withMigrationLock(db.WithContext(ctx), opts, func(conn *gorm.DB) error {
+		return withSessionTimeouts(conn, opts, func(conn *gorm.DB) error {
+			return conn.Exec("SELECT 1").Error
+		})
+	})
+	if err != nil {
+		t.Fatalf("expected the run to reuse the locked connection, got %v", err)
+	}
+	if server.held {
+		t.Fatalf("expected the lock to be released")
+	}
+}
+
+func TestSessionTimeoutsResetAfterCancel(t *testing.T) {
+	db, server := fakeLockDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	err := withSessionTimeouts(db.WithContext(ctx), MigrateOptions{StatementTimeout: time.Second}, func(*gorm.DB) error {
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation error, got %v", err)
+	}
+	execs := server.executed()
+	if len(execs) != 2 || execs[1] != "RESET statement_timeout" {
+		t.Fatalf("expected the session to be restored, got %v", execs)
+	}
+}
+
+func TestExpireTableLock(t *testing.T) {
+	db, server := fakeLockDB(t)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := expireTableLock(db, "driftflow_lock", "driftflow_migrations", now); err != nil {
+		t.Fatalf("expireTableLock: %v", err)
+	}
+	execs := server.executed()
+	if len(execs) != 1 || !strings.HasPrefix(execs[0], `DELETE FROM "driftflow_lock" WHERE name = $1 AND locked_at < $2`) {
+		t.Fatalf("unexpected statements: %v", execs)
+	}
+	if cutoff, ok := server.lastArgs[1].Value.(time.Time); !ok || !cutoff.Equal(now.Add(-StaleLockTimeout)) {
+		t.Fatalf("expected rows older than StaleLockTimeout to expire, got %v", server.lastArgs)
+	}
+}
@@ -0,0 +1,103 @@
+package driftflow
+
+import "sync"
+
+// MetaTables names the tables DriftFlow keeps its own data in. Empty fields
+// fall back to the tables set with SetMetaTables, then to the defaults.
+// Schema qualifies every table (on MySQL it names a database) and must
+// already exist. Two independent migration sets can share a database by
+// giving each its own History table; each then also gets its own migration
+// lock. Only the lock name differs: on engines without native locks both
+// sets keep their lock rows in the same driftflow_lock table of Schema.
+type MetaTables struct {
+	Schema string
+	// History defaults to migrations_history. Failed attempts are recorded
+	// in History + "_failures".
+	History string
+	// Audit defaults to schema_audit_log.
+	Audit string
+	// FieldHistory defaults to schema_field_history.
+	FieldHistory string
+}
+
+const (
+	defaultHistoryTable      = "migrations_history"
+	defaultAuditTable        = "schema_audit_log"
+	defaultFieldHistoryTable = "schema_field_history"
+	lockTableName            = "driftflow_lock"
+)
+
+var (
+	metaTablesMu      sync.RWMutex
+	defaultMetaTables MetaTables
+)
+
+// SetMetaTables sets the tables used where MigrateOptions.Tables,
+// CleanOptions.Tables or ResetOptions.Tables leave a field empty, and by
+// functions without options (Seed, LogAuditEvent, ListAuditLog,
+// ListMigrationFailures, GenerateMigrations, ...).
+func SetMetaTables(t MetaTables) {
+	metaTablesMu.Lock()
+	defer metaTablesMu.Unlock()
+	defaultMetaTables = t
+}
+
+// resolve fills the empty fields of t from the package tables and the
+// defaults.
+func (t MetaTables) resolve() MetaTables {
+	metaTablesMu.RLock()
+	pkg := defaultMetaTables
+	metaTablesMu.RUnlock()
+	pick := func(v, fallback, def string) string {
+		switch {
+		case v != "":
+			return v
+		case fallback != "":
+			return fallback
+		}
+		return def
+	}
+	return MetaTables{
+		Schema:       pick(t.Schema, pkg.Schema, ""),
+		History:      pick(t.History, pkg.History, defaultHistoryTable),
+		Audit:        pick(t.Audit, pkg.Audit, defaultAuditTable),
+		FieldHistory: pick(t.FieldHistory, pkg.FieldHistory, defaultFieldHistoryTable),
+	}
+}
+
+// packageTables returns the tables of functions without options.
+func packageTables() MetaTables {
+	return MetaTables{}.resolve()
+}
+
+// tables returns the resolved tables of the run.
+func (o MigrateOptions) tables() MetaTables {
+	return o.Tables.resolve()
+}
+
+func (t MetaTables) qualify(name string) string {
+	if t.Schema == "" {
+		return name
+	}
+	return t.Schema + "." + name
+}
+
+func (t MetaTables) historyTable() string      { return t.qualify(t.History) }
+func (t MetaTables) failuresTable() string     { return t.qualify(t.History + "_failures") }
+func (t MetaTables) auditTable() string        { return t.qualify(t.Audit) }
+func (t MetaTables) fieldHistoryTable() string { return t.qualify(t.FieldHistory) }
+func (t MetaTables) lockTable() string         { return t.qualify(lockTableName) }
+
+// names returns the unqualified names of all metadata tables.
+func (t MetaTables) names() []string {
+	return []string{t.History, t.History + "_failures", t.Audit, t.FieldHistory, lockTableName}
+}
+
+// lockName returns the name of the migration lock: the historical name for
+// the default history table, one derived from the table otherwise.
+func (t MetaTables) lockName() string {
+	if t.Schema == "" && t.History == defaultHistoryTable {
+		return migrationLockName
+	}
+	return migrationLockName + ":" + t.historyTable()
+}
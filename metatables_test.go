@@ -0,0 +1,107 @@
+package driftflow
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func withMetaTables(t *testing.T, m MetaTables) {
+	t.Helper()
+	SetMetaTables(m)
+	t.Cleanup(func() { SetMetaTables(MetaTables{}) })
+}
+
+func TestMetaTablesDefaults(t *testing.T) {
+	tables := MetaTables{}.resolve()
+	if tables.historyTable() != "migrations_history" || tables.failuresTable() != "migrations_history_failures" ||
+		tables.auditTable() != "schema_audit_log" || tables.fieldHistoryTable() != "schema_field_history" ||
+		tables.lockTable() != "driftflow_lock" {
+		t.Fatalf("unexpected defaults: %+v", tables)
+	}
+	if tables.lockName() != migrationLockName {
+		t.Fatalf("expected historical lock name, got %s", tables.lockName())
+	}
+}
+
+func TestMetaTablesFallback(t *testing.T) {
+	withMetaTables(t, MetaTables{Schema: "meta", Audit: "app_audit"})
+
+	tables := MigrateOptions{Tables: MetaTables{History: "plugin_history"}}.tables()
+	if tables.historyTable() != "meta.plugin_history" || tables.failuresTable() != "meta.plugin_history_failures" {
+		t.Fatalf("unexpected history tables: %s, %s", tables.historyTable(), tables.failuresTable())
+	}
+	if tables.auditTable() != "meta.app_audit" || tables.fieldHistoryTable() != "meta.schema_field_history" {
+		t.Fatalf("unexpected audit tables: %s, %s", tables.auditTable(), tables.fieldHistoryTable())
+	}
+	if tables.lockTable() != "meta.driftflow_lock" {
+		t.Fatalf("unexpected lock table: %s", tables.lockTable())
+	}
+	if packageTables().historyTable() != "meta.migrations_history" {
+		t.Fatalf("unexpected package history table: %s", packageTables().historyTable())
+	}
+}
+
+func TestMetaTablesLockNamePerHistory(t *testing.T) {
+	app := MetaTables{}.resolve().lockName()
+	plugin := MetaTables{History: "plugin_history"}.resolve().lockName()
+	meta := MetaTables{Schema: "meta"}.resolve().lockName()
+	if app == plugin || app == meta || plugin == meta {
+		t.Fatalf("expected distinct lock names, got %s, %s, %s", app, plugin, meta)
+	}
+}
+
+func TestCleanKeepsMigrationTables(t *testing.T) {
+	tables := []string{"users", "order_history", "plugin_history", "plugin_history_failures",
+		"tenant_history", "tenant_history_failures", "migrations_history", "schema_migrations", "driftflow_lock"}
+
+	// the configured set, another set sharing the schema and the lock
+	keep := keptTables(CleanOptions{KeepMigrations: true, Tables: MetaTables{History: "plugin_history"}}, "public", tables)
+	got := filterTables(tables, "", "", keep)
+	if want := []string{"users", "order_history"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// history configured in another schema
+	keep = keptTables(CleanOptions{KeepMigrations: true, Tables: MetaTables{Schema: "meta"}}, "public", tables)
+	got = filterTables(tables, "", "", keep)
+	if want := []string{"users", "order_history"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	// without KeepMigrations only the lock is left alone
+	got = filterTables(tables, "", "", keptTables(CleanOptions{}, "public", tables))
+	if len(got) != len(tables)-1 || slices.Contains(got, "driftflow_lock") {
+		t.Fatalf("expected every table but the lock, got %v", got)
+	}
+}
+
+func TestResetDropsExternalMetaTables(t *testing.T) {
+	db, server := fakeLockDB(t)
+	tables := MetaTables{Schema: "meta", History: "plugin_history"}.resolve()
+	if err := dropExternalHistory(db, tables, "public"); err != nil {
+		t.Fatalf("dropExternalHistory: %v", err)
+	}
+	got := strings.Join(server.executed(), "\n")
+	for _, want := range []string{
+		`DROP TABLE IF EXISTS "meta"."plugin_history" CASCADE`,
+		`DROP TABLE IF EXISTS "meta"."plugin_history_failures" CASCADE`,
+		`DROP TABLE IF EXISTS "meta"."schema_audit_log" CASCADE`,
+		`DROP TABLE IF EXISTS "meta"."schema_field_history" CASCADE`,
+		`DELETE FROM "meta"."driftflow_lock" WHERE name = $1 OR name LIKE $2`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `DROP TABLE IF EXISTS "meta"."driftflow_lock"`) {
+		t.Fatalf("the shared lock table must be kept:\n%s", got)
+	}
+
+	// nothing outside the reset schema
+	server.execs = nil
+	if err := dropExternalHistory(db, MetaTables{}.resolve(), "public"); err != nil || len(server.executed()) != 0 {
+		t.Fatalf("expected nothing dropped, got %v, %v", server.executed(), err)
+	}
+}
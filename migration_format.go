@@ -5,12 +5,23 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode"
 )
 
 const (
 	migrationUpMarker   = "-- +migrate Up"
 	migrationDownMarker = "-- +migrate Down"
+	// migrationSquashesMarker lines before the Up marker list the versions a
+	// squashed baseline replaces; see Squash.
+	migrationSquashesMarker = "-- +migrate Squashes"
+	// migrationDependsOnMarker lines before the Up marker list the versions a
+	// migration must follow, e.g. "-- +migrate DependsOn: 001_users,002_posts".
+	migrationDependsOnMarker = "-- +migrate DependsOn:"
+	// migrationIrreversibleMarker before the Up marker marks a migration that
+	// cannot be rolled back; see MigrateOptions.ForceIrreversible.
+	migrationIrreversibleMarker = "-- +migrate Irreversible"
 )
 
 func normalizeMigrationSection(sql string) string {
@@ -23,51 +34,263 @@ func formatMigrationFile(upSQL, downSQL string) string {
 	return fmt.Sprintf("%s\n%s\n\n%s\n%s\n", migrationUpMarker, up, migrationDownMarker, down)
 }
 
-func splitMigrationSections(contents string) (string, string, error) {
+// migrationScript is a parsed migration file.
+type migrationScript struct {
+	Up                string // every Up section
+	Down              string // every Down section
+	UpNoTransaction   bool
+	DownNoTransaction bool
+	Squashes          []string // versions replaced by a squashed baseline
+	DependsOn         []string // versions that must be applied first
+	Irreversible      bool
+	// Sections holds the Up and Down sections in file order.
+	Sections []migrationSection
+}
+
+// migrationSection is one Up or Down section of a migration file. A file may
+// split a direction into several sections and limit some of them to
+// environments or dialects, e.g. "-- +migrate Up env:dev,test" or
+// "-- +migrate Down dialect:postgres".
+type migrationSection struct {
+	Direction     string // "up" or "down"
+	NoTransaction bool
+	Envs          []string // empty runs in every environment
+	Dialects      []string // empty runs on every dialect
+	SQL           string
+}
+
+// marker returns the canonical marker line of the section.
+func (sec migrationSection) marker() string {
+	var sb strings.Builder
+	if sec.Direction == "up" {
+		sb.WriteString(migrationUpMarker)
+	} else {
+		sb.WriteString(migrationDownMarker)
+	}
+	if sec.NoTransaction {
+		sb.WriteString(" notransaction")
+	}
+	if len(sec.Envs) > 0 {
+		sb.WriteString(" env:" + strings.Join(sec.Envs, ","))
+	}
+	if len(sec.Dialects) > 0 {
+		sb.WriteString(" dialect:" + strings.Join(sec.Dialects, ","))
+	}
+	return sb.String()
+}
+
+// matches reports whether the section runs in env on dialect.
+func (sec migrationSection) matches(env, dialect string) bool {
+	if len(sec.Envs) > 0 && !slices.Contains(sec.Envs, strings.ToLower(strings.TrimSpace(env))) {
+		return false
+	}
+	return len(sec.Dialects) == 0 || slices.Contains(sec.Dialects, canonicalDialect(dialect))
+}
+
+// irreversible reports whether the migration cannot be rolled back in env on
+// dialect: it is marked Irreversible, or the sections selected there have Up
+// statements and no Down to undo them.
+func (s migrationScript) irreversible(env, dialect string) bool {
+	if s.Irreversible {
+		return true
+	}
+	up, _ := s.sql("up", env, dialect)
+	down, _ := s.sql("down", env, dialect)
+	return down == "" && up != ""
+}
+
+// selections returns the env and dialect pairs that pick distinct sections
+// of the script, including one matching no qualifier at all.
+func (s migrationScript) selections() [][2]string {
+	envs, dialects := []string{""}, []string{""}
+	for _, sec := range s.Sections {
+		for _, e := range sec.Envs {
+			if !slices.Contains(envs, e) {
+				envs = append(envs, e)
+			}
+		}
+		for _, d := range sec.Dialects {
+			if !slices.Contains(dialects, d) {
+				dialects = append(dialects, d)
+			}
+		}
+	}
+	var out [][2]string
+	for _, e := range envs {
+		for _, d := range dialects {
+			out = append(out, [2]string{e, d})
+		}
+	}
+	return out
+}
+
+// conditional reports whether the file goes beyond one plain Up and one plain
+// Down section.
+func (s migrationScript) conditional() bool {
+	if len(s.Sections) != 2 {
+		return true
+	}
+	for _, sec := range s.Sections {
+		if len(sec.Envs) > 0 || len(sec.Dialects) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sql returns the sections of direction that run in env on dialect, and
+// whether any of them is marked notransaction.
+func (s migrationScript) sql(direction, env, dialect string) (string, bool) {
 	var (
-		upLines   []string
-		downLines []string
-		seenUp    bool
-		seenDown  bool
-		state     string
+		parts []string
+		noTx  bool
+	)
+	for _, sec := range s.Sections {
+		if sec.Direction != direction || !sec.matches(env, dialect) {
+			continue
+		}
+		if sec.SQL != "" {
+			parts = append(parts, sec.SQL)
+		}
+		noTx = noTx || sec.NoTransaction
+	}
+	return strings.Join(parts, "\n\n"), noTx
+}
+
+// canonicalDialect maps dialect aliases to the names gorm dialectors report.
+func canonicalDialect(dialect string) string {
+	switch d := normalizeEngine(dialect); d {
+	case "postgresql":
+		return "postgres"
+	case "mssql":
+		return "sqlserver"
+	default:
+		return d
+	}
+}
+
+// parseMigrationMarker reports whether line is an Up or Down marker and
+// returns the section it opens with its options, e.g.
+// "-- +migrate Up notransaction env:dev dialect:postgres".
+func parseMigrationMarker(line string) (migrationSection, bool, error) {
+	var sec migrationSection
+	fields := strings.Fields(line)
+	if len(fields) < 3 || fields[0] != "--" || fields[1] != "+migrate" {
+		return sec, false, nil
+	}
+	switch fields[2] {
+	case "Up":
+		sec.Direction = "up"
+	case "Down":
+		sec.Direction = "down"
+	default:
+		return sec, false, nil
+	}
+	for _, opt := range fields[3:] {
+		key, value, qualified := strings.Cut(strings.ToLower(opt), ":")
+		switch {
+		case !qualified && key == "notransaction":
+			sec.NoTransaction = true
+		case qualified && (key == "env" || key == "dialect"):
+			var values []string
+			for _, v := range strings.Split(value, ",") {
+				if v == "" {
+					return sec, false, fmt.Errorf("empty %s qualifier in %s", key, strings.TrimSpace(line))
+				}
+				if key == "dialect" {
+					v = canonicalDialect(v)
+				}
+				values = append(values, v)
+			}
+			if key == "env" {
+				sec.Envs = append(sec.Envs, values...)
+			} else {
+				sec.Dialects = append(sec.Dialects, values...)
+			}
+		default:
+			return sec, false, fmt.Errorf("unknown option %q in %s", opt, strings.TrimSpace(line))
+		}
+	}
+	return sec, true, nil
+}
+
+func parseMigrationScript(contents string) (migrationScript, error) {
+	var (
+		script   migrationScript
+		lines    [][]string
+		seenUp   bool
+		seenDown bool
 	)
 
 	scanner := bufio.NewScanner(strings.NewReader(contents))
 	for scanner.Scan() {
 		line := scanner.Text()
-		switch strings.TrimSpace(line) {
-		case migrationUpMarker:
-			if seenUp || seenDown {
-				return "", "", fmt.Errorf("unexpected %s marker", migrationUpMarker)
-			}
-			seenUp = true
-			state = "up"
-			continue
-		case migrationDownMarker:
-			if !seenUp || seenDown {
-				return "", "", fmt.Errorf("unexpected %s marker", migrationDownMarker)
+		sec, isMarker, err := parseMigrationMarker(line)
+		if err != nil {
+			return migrationScript{}, err
+		}
+		if isMarker {
+			switch sec.Direction {
+			case "up":
+				if seenDown {
+					return migrationScript{}, fmt.Errorf("unexpected %s marker", migrationUpMarker)
+				}
+				seenUp = true
+				script.UpNoTransaction = script.UpNoTransaction || sec.NoTransaction
+			case "down":
+				if !seenUp {
+					return migrationScript{}, fmt.Errorf("unexpected %s marker", migrationDownMarker)
+				}
+				seenDown = true
+				script.DownNoTransaction = script.DownNoTransaction || sec.NoTransaction
 			}
-			seenDown = true
-			state = "down"
+			script.Sections = append(script.Sections, sec)
+			lines = append(lines, nil)
 			continue
 		}
 
-		switch state {
-		case "up":
-			upLines = append(upLines, line)
-		case "down":
-			downLines = append(downLines, line)
+		if len(lines) > 0 {
+			lines[len(lines)-1] = append(lines[len(lines)-1], line)
+		} else if rest, ok := strings.CutPrefix(strings.TrimSpace(line), migrationSquashesMarker+" "); ok {
+			script.Squashes = append(script.Squashes, strings.Fields(rest)...)
+		} else if strings.TrimSpace(line) == migrationIrreversibleMarker {
+			script.Irreversible = true
+		} else if rest, ok := strings.CutPrefix(strings.TrimSpace(line), migrationDependsOnMarker); ok {
+			script.DependsOn = append(script.DependsOn, strings.FieldsFunc(rest, func(r rune) bool {
+				return r == ',' || unicode.IsSpace(r)
+			})...)
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return "", "", err
+		return migrationScript{}, err
 	}
 	if !seenUp || !seenDown {
-		return "", "", fmt.Errorf("migration file missing required markers")
+		return migrationScript{}, fmt.Errorf("migration file missing required markers")
+	}
+	var up, down []string
+	for i := range script.Sections {
+		sec := &script.Sections[i]
+		sec.SQL = normalizeMigrationSection(strings.Join(lines[i], "\n"))
+		if sec.SQL == "" {
+			continue
+		}
+		if sec.Direction == "up" {
+			up = append(up, sec.SQL)
+		} else {
+			down = append(down, sec.SQL)
+		}
+	}
+	script.Up = strings.Join(up, "\n\n")
+	script.Down = strings.Join(down, "\n\n")
+	return script, nil
+}
+
+func splitMigrationSections(contents string) (string, string, error) {
+	script, err := parseMigrationScript(contents)
+	if err != nil {
+		return "", "", err
 	}
-	return normalizeMigrationSection(strings.Join(upLines, "\n")),
-		normalizeMigrationSection(strings.Join(downLines, "\n")),
-		nil
+	return script.Up, script.Down, nil
 }
 
 func readMigrationSections(path string) (string, string, error) {
@@ -78,16 +301,19 @@ func readMigrationSections(path string) (string, string, error) {
 	return splitMigrationSections(string(b))
 }
 
-func readMigrationFile(path string) (string, string, string, error) {
-	b, err := os.ReadFile(path)
+// readMigrationScript parses the migration at path in src and returns it with
+// its checksum under algorithm a.
+func readMigrationScript(src migrationSource, path string, a ChecksumAlgorithm) (migrationScript, string, error) {
+	b, err := src.readFile(path)
 	if err != nil {
-		return "", "", "", err
+		return migrationScript{}, "", err
 	}
-	upSQL, downSQL, err := splitMigrationSections(string(b))
+	script, err := parseMigrationScript(string(b))
 	if err != nil {
-		return "", "", "", err
+		return migrationScript{}, "", err
 	}
-	return upSQL, downSQL, sha256Hex(b), nil
+	checksum, err := migrationChecksum(b, script, a)
+	return script, checksum, err
 }
 
 func writeMigrationFile(dir, baseName, upSQL, downSQL string) error {
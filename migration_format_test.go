@@ -0,0 +1,127 @@
+package driftflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseMigrationScriptNoTransaction(t *testing.T) {
+	contents := "-- +migrate Up notransaction\nCREATE INDEX CONCURRENTLY idx_users_email ON users (email);\n\n-- +migrate Down\nDROP INDEX idx_users_email;\n"
+	script, err := parseMigrationScript(contents)
+	if err != nil {
+		t.Fatalf("parseMigrationScript: %v", err)
+	}
+	if !script.UpNoTransaction || script.DownNoTransaction {
+		t.Fatalf("unexpected transaction flags: %+v", script)
+	}
+	if script.Up != "CREATE INDEX CONCURRENTLY idx_users_email ON users (email);" || script.Down != "DROP INDEX idx_users_email;" {
+		t.Fatalf("unexpected sections: %+v", script)
+	}
+}
+
+func TestParseMigrationScriptUnknownOption(t *testing.T) {
+	if _, err := parseMigrationScript("-- +migrate Up nosuchthing\nSELECT 1;\n-- +migrate Down\n"); err == nil {
+		t.Fatalf("expected unknown option error")
+	}
+}
+
+func TestSplitMigrationSectionsPlainMarkers(t *testing.T) {
+	up, down, err := splitMigrationSections(formatMigrationFile("CREATE TABLE t(id int);", "DROP TABLE t;"))
+	if err != nil {
+		t.Fatalf("splitMigrationSections: %v", err)
+	}
+	if up != "CREATE TABLE t(id int);" || down != "DROP TABLE t;" {
+		t.Fatalf("unexpected sections: %q %q", up, down)
+	}
+}
+
+const qualifiedMigration = `-- +migrate Up
+CREATE TABLE users(id int);
+
+-- +migrate Up dialect:postgresql
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+-- +migrate Up env:dev,test
+CREATE VIEW sample_users AS SELECT 1;
+
+-- +migrate Down env:dev,test
+DROP VIEW sample_users;
+
+-- +migrate Down
+DROP TABLE users;
+`
+
+func TestParseMigrationScriptQualifiedSections(t *testing.T) {
+	script, err := parseMigrationScript(qualifiedMigration)
+	if err != nil {
+		t.Fatalf("parseMigrationScript: %v", err)
+	}
+	if len(script.Sections) != 5 || !script.conditional() {
+		t.Fatalf("unexpected sections: %+v", script.Sections)
+	}
+	if got := script.Sections[1].marker(); got != "-- +migrate Up dialect:postgres" {
+		t.Fatalf("unexpected canonical marker %q", got)
+	}
+
+	tests := []struct {
+		direction, env, dialect, want string
+	}{
+		{"up", "dev", "postgres", "CREATE TABLE users(id int);\n\nCREATE EXTENSION IF NOT EXISTS pgcrypto;\n\nCREATE VIEW sample_users AS SELECT 1;"},
+		{"up", "production", "mysql", "CREATE TABLE users(id int);"},
+		{"up", "", "postgres", "CREATE TABLE users(id int);\n\nCREATE EXTENSION IF NOT EXISTS pgcrypto;"},
+		{"down", "TEST", "sqlite", "DROP VIEW sample_users;\n\nDROP TABLE users;"},
+		{"down", "production", "sqlite", "DROP TABLE users;"},
+	}
+	for _, tt := range tests {
+		if got, _ := script.sql(tt.direction, tt.env, tt.dialect); got != tt.want {
+			t.Fatalf("%s env=%q dialect=%q: got %q, want %q", tt.direction, tt.env, tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestParseMigrationScriptQualifierErrors(t *testing.T) {
+	for _, contents := range []string{
+		"-- +migrate Up env:\nSELECT 1;\n-- +migrate Down\n",
+		"-- +migrate Up region:eu\nSELECT 1;\n-- +migrate Down\n",
+		"-- +migrate Up\nSELECT 1;\n-- +migrate Down\n-- +migrate Up env:dev\nSELECT 2;\n",
+	} {
+		if _, err := parseMigrationScript(contents); err == nil {
+			t.Fatalf("expected error for %q", contents)
+		}
+	}
+}
+
+func TestQualifiedSectionsPlanAndChecksum(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "001_users.sql"), []byte(qualifiedMigration), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestState(t, dir)
+	s.dialect = "mysql"
+	s.opts.Environment = "production"
+	prod, err := s.upStep("001_users")
+	if err != nil {
+		t.Fatalf("upStep: %v", err)
+	}
+	s.dialect = "postgres"
+	s.opts.Environment = "dev"
+	dev, err := s.upStep("001_users")
+	if err != nil {
+		t.Fatalf("upStep: %v", err)
+	}
+	if len(prod.Statements) != 1 || len(dev.Statements) != 3 {
+		t.Fatalf("unexpected statements: %v / %v", prod.Statements, dev.Statements)
+	}
+	if prod.Checksum != dev.Checksum {
+		t.Fatalf("checksum must not depend on the selected sections")
+	}
+
+	edited := strings.Replace(qualifiedMigration, "env:dev,test", "env:dev", 1)
+	a, _ := fileChecksum("001_users.sql", []byte(qualifiedMigration), ChecksumNormalized)
+	b, _ := fileChecksum("001_users.sql", []byte(edited), ChecksumNormalized)
+	if a != prod.Checksum || a == b {
+		t.Fatalf("expected qualifiers to count in the checksum")
+	}
+}
@@ -1,8 +1,10 @@
 package driftflow
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -16,49 +18,139 @@ import (
 	"gorm.io/gorm/schema"
 
 	"gorm.io/gorm"
-
-	"github.com/misaelcrespo30/DriftFlow/config"
 )
 
-// SchemaMigration represents a row in the schema_migrations table.
+// SchemaMigration represents a row in the schema_migrations table. The
+// columns after AppliedAt were added later; ensureMigrationsTable adds them to
+// existing tables with defaults, so older rows read as applied with no
+// duration or run details.
 type SchemaMigration struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Version   string    `gorm:"uniqueIndex" json:"version"`
-	Batch     int       `gorm:"not null"`
-	Checksum  string    `gorm:"size:64;not null"` // sha256
-	AppliedAt time.Time `gorm:"autoCreateTime" json:"applied_at"`
+	ID               uint              `gorm:"primaryKey" json:"id"`
+	Version          string            `gorm:"uniqueIndex" json:"version"`
+	Batch            int               `gorm:"not null"`
+	Checksum         string            `gorm:"size:64;not null"` // sha256
+	ChecksumVersion  ChecksumAlgorithm `gorm:"not null;default:1" json:"checksum_version"`
+	AppliedAt        time.Time         `gorm:"autoCreateTime" json:"applied_at"`
+	DurationMs       int64             `gorm:"not null;default:0" json:"duration_ms"`
+	DBUser           string            `gorm:"size:128" json:"db_user,omitempty"`
+	Host             string            `gorm:"size:255" json:"host,omitempty"`
+	DriftflowVersion string            `gorm:"size:64" json:"driftflow_version,omitempty"`
+	Status           HistoryStatus     `gorm:"size:16;not null;default:applied" json:"status"`
 }
 
 func (SchemaMigration) TableName() string {
 	return "migrations_history"
 }
 
-// ensureMigrationsTable creates the schema_migrations table if it does not exist.
-func ensureMigrationsTable(db *gorm.DB) error {
-	return db.AutoMigrate(&SchemaMigration{})
+// MigrateOptions tunes how Up, MigrateTo and DownSteps run. The zero value
+// gives the default behaviour.
+type MigrateOptions struct {
+	// LockWaitTimeout bounds how long a run waits for the migration lock held
+	// by another process. Zero uses DefaultLockWaitTimeout.
+	LockWaitTimeout time.Duration
+	// DisableLock skips the cross-process migration lock.
+	DisableLock bool
+	// AllowOutOfOrder applies pending migrations older than the latest applied
+	// one (e.g. merged from a long-lived branch). By default such a gap is
+	// reported as ErrOutOfOrder.
+	AllowOutOfOrder bool
+	// StatementTimeout bounds each migration statement: statement_timeout on
+	// Postgres, a per-statement context deadline elsewhere. Zero disables it.
+	StatementTimeout time.Duration
+	// LockTimeout bounds how long a statement waits for table or row locks:
+	// lock_timeout on Postgres, innodb_lock_wait_timeout and lock_wait_timeout
+	// on MySQL, SET LOCK_TIMEOUT on SQL Server. Zero keeps the server default.
+	LockTimeout time.Duration
+	// Hooks is called around the executed steps; see MigrationHooks.
+	Hooks MigrationHooks
+	// Observer receives progress and failure events of the run. Nil uses the
+	// observer set with SetObserver.
+	Observer Observer
+	// Tables names the history, audit and field history tables of the run.
+	Tables MetaTables
+	// ChecksumAlgorithm computes the checksums of the run. Zero uses the
+	// algorithm set with SetChecksumAlgorithm.
+	ChecksumAlgorithm ChecksumAlgorithm
+	// Environment selects the migration sections qualified with "env:".
+	// Empty uses the ENV environment variable.
+	Environment string
+	// ForceIrreversible rolls back irreversible migrations anyway: their Down
+	// section, if any, runs and their history row is removed.
+	ForceIrreversible bool
+	// GoMigrations are the Go migrations of the migration set, run in version
+	// order with its .sql files. Runs of the same directory must pass the
+	// same list; without it the Go entries of the manifest are not checked.
+	GoMigrations []GoMigration
+
+	tenant string // set by the tenant runner to scope the migration lock
 }
 
-func ensureManifestIntegrity(dir string) error {
-	manifestPath := filepath.Join(dir, "manifest.lock.json")
-	manifest, err := loadManifest(manifestPath)
-	if err != nil {
+// environment returns the environment of the run.
+func (o MigrateOptions) environment() string {
+	if o.Environment != "" {
+		return o.Environment
+	}
+	return os.Getenv("ENV")
+}
+
+// ensureMigrationsTable creates the history table and its failures companion
+// if they do not exist, and adds missing columns to older tables.
+func ensureMigrationsTable(db *gorm.DB, t MetaTables) error {
+	if err := db.Table(t.historyTable()).AutoMigrate(&SchemaMigration{}); err != nil {
 		return err
 	}
-	migrated, err := migrateManifest(dir, manifest)
+	return db.Table(t.failuresTable()).AutoMigrate(&MigrationFailure{})
+}
+
+// checkSource validates src and its manifest before a run, reporting
+// manifest issues to obs.
+func checkSource(src migrationSource, obs Observer) error {
+	if err := src.validate(); err != nil {
+		return err
+	}
+	return ensureManifestIntegrity(src, obs)
+}
+
+func ensureManifestIntegrity(src migrationSource, obs Observer) error {
+	_, issues, err := checkManifest(src)
 	if err != nil {
 		return err
 	}
+	return manifestIssuesError(obs, issues)
+}
+
+// checkManifest reads the manifest of src and returns it with its issues.
+func checkManifest(src migrationSource) (*ManifestLock, []ManifestIssue, error) {
+	manifest, err := readManifest(src, src.path(manifestFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	migrated, err := migrateManifest(src, manifest)
+	if err != nil {
+		return nil, nil, err
+	}
 	if migrated {
-		return fmt.Errorf("manifest.lock.json needs migration; run driftflow generate to update it")
+		return nil, nil, fmt.Errorf("manifest.lock.json needs migration; run driftflow generate to update it")
 	}
-
-	issues, err := validateManifest(dir, manifest)
+	// entries of another algorithm are checked with the current one, in
+	// memory; generate writes them back
+	if _, err := upgradeManifestChecksums(src, manifest); err != nil {
+		return nil, nil, err
+	}
+	issues, err := validateManifest(src, manifest)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	return manifest, issues, nil
+}
+
+// manifestIssuesError reports issues to obs and returns them as one error,
+// or nil when there are none.
+func manifestIssuesError(obs Observer, issues []ManifestIssue) error {
 	if len(issues) == 0 {
 		return nil
 	}
+	reportManifestIssues(obs, issues)
 
 	var sb strings.Builder
 	sb.WriteString("manifest validation failed:\n")
@@ -80,19 +172,25 @@ func ensureManifestIntegrity(dir string) error {
 		}
 		sb.WriteString("\n")
 	}
-	return fmt.Errorf(strings.TrimSpace(sb.String()))
+	return errors.New(strings.TrimSpace(sb.String()))
 }
 
-// readMigrationFiles returns the migration files sorted by name.
-func readMigrationFiles(dir string) ([]string, error) {
-	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+// readMigrationFiles returns the migration files in src sorted by name.
+func readMigrationFiles(src migrationSource) ([]string, error) {
+	files, err := src.glob("*.sql")
 	if err != nil {
 		return nil, err
 	}
-	sort.Slice(files, func(i, j int) bool {
-		return migrationVersionFromFilename(files[i]) < migrationVersionFromFilename(files[j])
+	migrations := files[:0]
+	for _, f := range files {
+		if !isCallbackFile(f) && !isRepeatableFile(f) {
+			migrations = append(migrations, f)
+		}
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrationVersionFromFilename(migrations[i]) < migrationVersionFromFilename(migrations[j])
 	})
-	return files, nil
+	return migrations, nil
 }
 
 func migrationVersion(path string) string {
@@ -107,9 +205,9 @@ func recordMigration(db *gorm.DB, version string) error {
 	return db.Where("version = ?", version).FirstOrCreate(&m).Error
 }
 
-// removeMigration removes a migration record by version.
-func removeMigration(db *gorm.DB, version string) error {
-	return db.Where("version = ?", version).Delete(&SchemaMigration{}).Error
+// removeMigration removes a migration record by version from table.
+func removeMigration(db *gorm.DB, table, version string) error {
+	return db.Table(table).Where("version = ?", version).Delete(&SchemaMigration{}).Error
 }
 
 // toSnakeWithInitialisms converts CamelCase names to snake_case and keeps
@@ -343,71 +441,53 @@ func modelsSchema(db *gorm.DB, models []interface{}) (schemaInfo, error) {
 	return nil
 }*/
 
+// Up applies all pending migrations found in dir.
 func Up(db *gorm.DB, dir string) error {
-	if err := config.ValidateDir(dir); err != nil {
-		return err
-	}
-	if err := ensureManifestIntegrity(dir); err != nil {
-		return err
-	}
-	if err := ensureMigrationsTable(db); err != nil {
-		return err
-	}
+	return UpWithOptions(db, dir, MigrateOptions{})
+}
 
-	ups, err := readMigrationFiles(dir)
-	if err != nil {
+// UpWithOptions applies all pending migrations found in dir while holding the
+// migration lock.
+func UpWithOptions(db *gorm.DB, dir string, opts MigrateOptions) error {
+	return up(db, dirSource(dir).withGoMigrations(opts.GoMigrations), opts)
+}
+
+// UpFS is UpWithOptions reading the migrations from the root of fsys, such as
+// an embed.FS narrowed with fs.Sub. The manifest is verified like on disk.
+func UpFS(db *gorm.DB, fsys fs.FS, opts MigrateOptions) error {
+	return up(db, fsSource(fsys).withGoMigrations(opts.GoMigrations), opts)
+}
+
+// UpContext is UpWithOptions bound to ctx. Cancelling ctx aborts the running
+// statement, rolls back its migration and releases the migration lock.
+func UpContext(ctx context.Context, db *gorm.DB, dir string, opts MigrateOptions) error {
+	return UpWithOptions(db.WithContext(ctx), dir, opts)
+}
+
+func up(db *gorm.DB, src migrationSource, opts MigrateOptions) error {
+	if err := checkSource(src, opts.observer()); err != nil {
 		return err
 	}
+	return runUp(db, src, opts)
+}
 
-	// nuevo batch = max(batch)+1
-	var lastBatch int
-	_ = db.Model(&SchemaMigration{}).
-		Select("COALESCE(MAX(batch),0)").
-		Scan(&lastBatch).Error
-	newBatch := lastBatch + 1
-
-	for _, f := range ups {
-		version := migrationVersionFromFilename(f) // ✅ usa filename estable
-		upSQL, _, checksum, err := readMigrationFile(f)
-		if err != nil {
+// runUp applies the pending migrations of an already checked src.
+func runUp(db *gorm.DB, src migrationSource, opts MigrateOptions) error {
+	return withMigrationLock(db, opts, func(db *gorm.DB) error {
+		if err := ensureMigrationsTable(db, opts.tables()); err != nil {
 			return err
 		}
-
-		// ya aplicada?
-		var m SchemaMigration
-		result := db.Where("version = ?", version).Limit(1).Find(&m)
-		if result.Error != nil {
-			return result.Error
-		}
-		if result.RowsAffected > 0 {
-			// ✅ si ya existe, valida checksum (opcional pero recomendado)
-			if m.Checksum != checksum {
-				return fmt.Errorf("migration modified after applied: %s", version)
-			}
-			continue
+		ensureAuditTables(db, opts, false)
+		state, err := loadMigrationState(db, src, opts)
+		if err != nil {
+			return err
 		}
-
-		// ✅ aplicar en transacción
-		if err := db.Transaction(func(tx *gorm.DB) error {
-			if err := tx.Exec(upSQL).Error; err != nil {
-				return fmt.Errorf("apply %s: %w", f, err)
-			}
-			rec := SchemaMigration{
-				Version:   version,
-				Batch:     newBatch,
-				Checksum:  checksum,
-				AppliedAt: time.Now().UTC(),
-			}
-			if err := tx.Create(&rec).Error; err != nil {
-				return err
-			}
-			return nil
-		}); err != nil {
+		plan, err := state.planUp()
+		if err != nil {
 			return err
 		}
-	}
-
-	return nil
+		return state.execute(db, plan)
+	})
 }
 
 func migrationVersionFromFilename(path string) string {
@@ -429,195 +509,84 @@ func Down(db *gorm.DB, dir string, targetVersion string) error {
 // or greater than the number of applied migrations, all applied migrations are
 // rolled back.
 func DownSteps(db *gorm.DB, dir string, steps int) error {
-	if err := config.ValidateDir(dir); err != nil {
-		return err
-	}
-	if err := ensureManifestIntegrity(dir); err != nil {
-		return err
-	}
-	if err := ensureMigrationsTable(db); err != nil {
-		return err
-	}
-	_ = EnsureAuditTable(db)
-	_ = EnsureFieldHistoryTable(db)
-	downs, err := readMigrationFiles(dir)
-	if err != nil {
-		return err
-	}
-	downMap := make(map[string]string)
-	var versions []string
-	for _, f := range downs {
-		version := migrationVersionFromFilename(f)
-		downMap[version] = f
-		versions = append(versions, version)
-	}
-	var applied []SchemaMigration
-	if err := db.Order("id asc").Find(&applied).Error; err != nil {
+	return DownStepsWithOptions(db, dir, steps, MigrateOptions{})
+}
+
+// DownStepsWithOptions is DownSteps with explicit runner options.
+func DownStepsWithOptions(db *gorm.DB, dir string, steps int, opts MigrateOptions) error {
+	return downSteps(db, dirSource(dir).withGoMigrations(opts.GoMigrations), steps, opts)
+}
+
+// DownStepsFS is DownStepsWithOptions reading the migrations from fsys.
+func DownStepsFS(db *gorm.DB, fsys fs.FS, steps int, opts MigrateOptions) error {
+	return downSteps(db, fsSource(fsys).withGoMigrations(opts.GoMigrations), steps, opts)
+}
+
+// DownStepsContext is DownStepsWithOptions bound to ctx.
+func DownStepsContext(ctx context.Context, db *gorm.DB, dir string, steps int, opts MigrateOptions) error {
+	return DownStepsWithOptions(db.WithContext(ctx), dir, steps, opts)
+}
+
+func downSteps(db *gorm.DB, src migrationSource, steps int, opts MigrateOptions) error {
+	if err := checkSource(src, opts.observer()); err != nil {
 		return err
 	}
-	appliedSet := make(map[string]SchemaMigration, len(applied))
-	for _, m := range applied {
-		appliedSet[m.Version] = m
-	}
-	var appliedOrdered []string
-	for _, v := range versions {
-		if _, ok := appliedSet[v]; ok {
-			appliedOrdered = append(appliedOrdered, v)
-		}
-	}
-	if steps < 1 || steps > len(appliedOrdered) {
-		steps = len(appliedOrdered)
-	}
-	for i := 0; i < steps; i++ {
-		version := appliedOrdered[len(appliedOrdered)-1-i]
-		file, ok := downMap[version]
-		if !ok {
-			return fmt.Errorf("missing down file for %s", version)
+	return withMigrationLock(db, opts, func(db *gorm.DB) error {
+		if err := ensureMigrationsTable(db, opts.tables()); err != nil {
+			return err
 		}
-		_, downSQL, checksum, err := readMigrationFile(file)
+		ensureAuditTables(db, opts, true)
+		state, err := loadMigrationState(db, src, opts)
 		if err != nil {
 			return err
 		}
-		if appliedSet[version].Checksum != checksum {
-			return fmt.Errorf("migration modified after applied: %s", version)
-		}
-		if err := db.Exec(downSQL).Error; err != nil {
-			return fmt.Errorf("revert %s: %w", file, err)
-		}
-		if err := removeMigration(db, version); err != nil {
+		plan, err := state.planDownSteps(steps)
+		if err != nil {
 			return err
 		}
-		LogAuditEvent(db, version, "rollback")
-	}
-	return nil
+		return state.execute(db, plan)
+	})
 }
 
 // MigrateTo applies or rolls back migrations until the target version is reached.
 func MigrateTo(db *gorm.DB, dir string, targetVersion string) error {
-	if err := config.ValidateDir(dir); err != nil {
-		return err
-	}
-	if err := ensureManifestIntegrity(dir); err != nil {
-		return err
-	}
-	if err := ensureMigrationsTable(db); err != nil {
-		return err
-	}
-	_ = EnsureAuditTable(db)
-	_ = EnsureFieldHistoryTable(db)
-
-	files, err := readMigrationFiles(dir)
-	if err != nil {
-		return err
-	}
-	versionToFile := make(map[string]string, len(files))
-	var versions []string
-	for _, f := range files {
-		version := migrationVersionFromFilename(f)
-		versionToFile[version] = f
-		versions = append(versions, version)
-	}
+	return MigrateToWithOptions(db, dir, targetVersion, MigrateOptions{})
+}
 
-	targetIndex := -1
-	for i, v := range versions {
-		if v == targetVersion {
-			targetIndex = i
-			break
-		}
-	}
-	if targetIndex == -1 {
-		return fmt.Errorf("target version not found: %s", targetVersion)
-	}
+// MigrateToWithOptions is MigrateTo with explicit runner options.
+func MigrateToWithOptions(db *gorm.DB, dir string, targetVersion string, opts MigrateOptions) error {
+	return migrateTo(db, dirSource(dir).withGoMigrations(opts.GoMigrations), targetVersion, opts)
+}
 
-	var applied []SchemaMigration
-	if err := db.Order("id asc").Find(&applied).Error; err != nil {
-		return err
-	}
-	appliedSet := make(map[string]SchemaMigration, len(applied))
-	for _, m := range applied {
-		if _, ok := versionToFile[m.Version]; !ok {
-			return fmt.Errorf("applied migration missing from disk: %s", m.Version)
-		}
-		appliedSet[m.Version] = m
-	}
+// MigrateToFS is MigrateToWithOptions reading the migrations from fsys.
+func MigrateToFS(db *gorm.DB, fsys fs.FS, targetVersion string, opts MigrateOptions) error {
+	return migrateTo(db, fsSource(fsys).withGoMigrations(opts.GoMigrations), targetVersion, opts)
+}
 
-	currentIndex := -1
-	seenGap := false
-	for i, v := range versions {
-		if _, ok := appliedSet[v]; ok {
-			if seenGap {
-				return fmt.Errorf("applied migrations are not contiguous; missing %s", versions[currentIndex+1])
-			}
-			currentIndex = i
-		} else if currentIndex != -1 {
-			seenGap = true
-		}
-	}
+// MigrateToContext is MigrateToWithOptions bound to ctx.
+func MigrateToContext(ctx context.Context, db *gorm.DB, dir string, targetVersion string, opts MigrateOptions) error {
+	return MigrateToWithOptions(db.WithContext(ctx), dir, targetVersion, opts)
+}
 
-	if currentIndex == targetIndex {
-		return nil
+func migrateTo(db *gorm.DB, src migrationSource, targetVersion string, opts MigrateOptions) error {
+	if err := checkSource(src, opts.observer()); err != nil {
+		return err
 	}
-
-	if currentIndex < targetIndex {
-		var lastBatch int
-		_ = db.Model(&SchemaMigration{}).
-			Select("COALESCE(MAX(batch),0)").
-			Scan(&lastBatch).Error
-		newBatch := lastBatch + 1
-
-		for i := currentIndex + 1; i <= targetIndex; i++ {
-			version := versions[i]
-			file := versionToFile[version]
-			upSQL, _, checksum, err := readMigrationFile(file)
-			if err != nil {
-				return err
-			}
-
-			if err := db.Transaction(func(tx *gorm.DB) error {
-				if err := tx.Exec(upSQL).Error; err != nil {
-					return fmt.Errorf("apply %s: %w", file, err)
-				}
-				rec := SchemaMigration{
-					Version:   version,
-					Batch:     newBatch,
-					Checksum:  checksum,
-					AppliedAt: time.Now().UTC(),
-				}
-				if err := tx.Create(&rec).Error; err != nil {
-					return err
-				}
-				return nil
-			}); err != nil {
-				return err
-			}
-			LogAuditEvent(db, version, "apply")
+	return withMigrationLock(db, opts, func(db *gorm.DB) error {
+		if err := ensureMigrationsTable(db, opts.tables()); err != nil {
+			return err
 		}
-		return nil
-	}
-
-	for i := currentIndex; i > targetIndex; i-- {
-		version := versions[i]
-		file := versionToFile[version]
-		_, downSQL, checksum, err := readMigrationFile(file)
+		ensureAuditTables(db, opts, true)
+		state, err := loadMigrationState(db, src, opts)
 		if err != nil {
 			return err
 		}
-		applied, ok := appliedSet[version]
-		if !ok {
-			return fmt.Errorf("applied migration missing from db: %s", version)
-		}
-		if applied.Checksum != checksum {
-			return fmt.Errorf("migration modified after applied: %s", version)
-		}
-		if err := db.Exec(downSQL).Error; err != nil {
-			return fmt.Errorf("revert %s: %w", file, err)
-		}
-		if err := removeMigration(db, version); err != nil {
+		plan, err := state.planMigrateTo(targetVersion)
+		if err != nil {
 			return err
 		}
-		LogAuditEvent(db, version, "rollback")
-	}
-	return nil
+		return state.execute(db, plan)
+	})
 }
 
 // GenerateMigrations is a placeholder for automatic generation.
@@ -625,11 +594,16 @@ func MigrateTo(db *gorm.DB, dir string, targetVersion string) error {
 // for any new tables or columns found in the provided models. Only basic
 // additions are handled.
 func GenerateMigrations(db *gorm.DB, models []interface{}, dir string) error {
-	fmt.Printf("GenerateMigrations dir=%s\n", dir)
+	return GenerateMigrationsWithOptions(db, models, dir, MigrateOptions{})
+}
+
+// GenerateMigrationsWithOptions is GenerateMigrations writing the field
+// history to opts.MetaTables and its events to opts.Observer.
+func GenerateMigrationsWithOptions(db *gorm.DB, models []interface{}, dir string, opts MigrateOptions) error {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return err
 	}
-	if err := EnsureFieldHistoryTable(db); err != nil {
+	if err := db.Table(opts.tables().fieldHistoryTable()).AutoMigrate(&FieldHistory{}); err != nil {
 		return err
 	}
 
@@ -769,24 +743,25 @@ func GenerateMigrations(db *gorm.DB, models []interface{}, dir string) error {
 		if err := writeMigrationFile(dir, name, upSQL, downSQL); err != nil {
 			return err
 		}
+		opts.observer().OnEvent(Event{Kind: EventMigrationWritten, Version: name, File: filepath.Join(dir, name+".sql")})
 
 		for col, typ := range ch.add {
-			logFieldAdd(db, name, tbl, col, typ)
+			logFieldAdd(db, opts, name, tbl, col, typ)
 		}
 		for col, typ := range ch.remove {
-			logFieldRemove(db, name, tbl, col, typ)
+			logFieldRemove(db, opts, name, tbl, col, typ)
 		}
 		for _, a := range ch.alters {
-			logFieldAlter(db, name, tbl, a.col, a.from, a.to)
+			logFieldAlter(db, opts, name, tbl, a.col, a.from, a.to)
 		}
 		if ch.create {
 			for col, typ := range modelSchema[tbl] {
-				logFieldAdd(db, name, tbl, col, typ)
+				logFieldAdd(db, opts, name, tbl, col, typ)
 			}
 		}
 		if ch.drop {
 			for col, typ := range dbSchema[tbl] {
-				logFieldRemove(db, name, tbl, col, typ)
+				logFieldRemove(db, opts, name, tbl, col, typ)
 			}
 		}
 	}
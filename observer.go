@@ -0,0 +1,120 @@
+package driftflow
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// EventKind identifies the type of an Event.
+type EventKind string
+
+const (
+	EventMigrationStarted  EventKind = "migration_started"
+	EventMigrationApplied  EventKind = "migration_applied"
+	EventMigrationReverted EventKind = "migration_reverted"
+	EventMigrationFailed   EventKind = "migration_failed"
+	EventMigrationWritten  EventKind = "migration_written"
+	EventSeedFileLoaded    EventKind = "seed_file_loaded"
+	EventManifestIssue     EventKind = "manifest_issue"
+	EventAuditWriteFailed  EventKind = "audit_write_failed"
+	EventNotice            EventKind = "notice"
+)
+
+// Event is what DriftFlow reports to an Observer. Only the fields relevant to
+// Kind are set.
+type Event struct {
+	Kind      EventKind
+	Tenant    string // set by the tenant runner
+	Version   string
+	Direction MigrationDirection
+	File      string
+	Duration  time.Duration
+	Issue     *ManifestIssue
+	Message   string
+	Err       error
+}
+
+// Observer receives progress and failure events. OnEvent must be safe for
+// concurrent use and should return quickly.
+type Observer interface {
+	OnEvent(Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) OnEvent(e Event) { f(e) }
+
+var (
+	observerMu      sync.RWMutex
+	defaultObserver Observer
+)
+
+// SetObserver sets the observer used when no MigrateOptions.Observer is given
+// and by functions without options (Seed, GenerateMigrations, LogAuditEvent,
+// ...). Pass nil to discard events.
+func SetObserver(o Observer) {
+	observerMu.Lock()
+	defer observerMu.Unlock()
+	defaultObserver = o
+}
+
+// notify reports e to the package observer, if any.
+func notify(e Event) {
+	observerMu.RLock()
+	o := defaultObserver
+	observerMu.RUnlock()
+	if o != nil {
+		o.OnEvent(e)
+	}
+}
+
+// observer returns the run's observer, falling back to the package one.
+func (o MigrateOptions) observer() Observer {
+	if o.Observer != nil {
+		return o.Observer
+	}
+	return ObserverFunc(notify)
+}
+
+// SlogObserver logs events to logger: failures at error level, manifest
+// issues and notices at warn level and everything else at info level.
+func SlogObserver(logger *slog.Logger) Observer {
+	return ObserverFunc(func(e Event) {
+		level := slog.LevelInfo
+		switch {
+		case e.Err != nil:
+			level = slog.LevelError
+		case e.Kind == EventManifestIssue, e.Kind == EventNotice:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{}
+		if e.Tenant != "" {
+			attrs = append(attrs, slog.String("tenant", e.Tenant))
+		}
+		if e.Version != "" {
+			attrs = append(attrs, slog.String("version", e.Version))
+		}
+		if e.Direction != "" {
+			attrs = append(attrs, slog.String("direction", string(e.Direction)))
+		}
+		if e.File != "" {
+			attrs = append(attrs, slog.String("file", e.File))
+		}
+		if e.Duration > 0 {
+			attrs = append(attrs, slog.Duration("duration", e.Duration))
+		}
+		if e.Issue != nil {
+			attrs = append(attrs, slog.String("issue", string(e.Issue.Type)), slog.String("migration", e.Issue.Migration))
+		}
+		if e.Message != "" {
+			attrs = append(attrs, slog.String("message", e.Message))
+		}
+		if e.Err != nil {
+			attrs = append(attrs, slog.Any("error", e.Err))
+		}
+		logger.LogAttrs(context.Background(), level, string(e.Kind), attrs...)
+	})
+}
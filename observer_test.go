@@ -0,0 +1,109 @@
+package driftflow
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureEvents installs a package observer for the duration of the test.
+func captureEvents(t *testing.T) *[]Event {
+	t.Helper()
+	var events []Event
+	SetObserver(ObserverFunc(func(e Event) { events = append(events, e) }))
+	t.Cleanup(func() { SetObserver(nil) })
+	return &events
+}
+
+func TestReportAuditErrorOnlyOnFailure(t *testing.T) {
+	var events []Event
+	obs := ObserverFunc(func(e Event) { events = append(events, e) })
+
+	reportAuditError(obs, "001_users", "apply", nil)
+	if len(events) != 0 {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	boom := errors.New("boom")
+	reportAuditError(obs, "001_users", "apply", boom)
+	if len(events) != 1 || events[0].Kind != EventAuditWriteFailed || !errors.Is(events[0].Err, boom) || events[0].Version != "001_users" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestMigrateOptionsObserverOverridesPackageObserver(t *testing.T) {
+	global := captureEvents(t)
+	var own []Event
+	opts := MigrateOptions{Observer: ObserverFunc(func(e Event) { own = append(own, e) })}
+
+	opts.observer().OnEvent(Event{Kind: EventMigrationStarted})
+	MigrateOptions{}.observer().OnEvent(Event{Kind: EventMigrationApplied})
+
+	if len(own) != 1 || own[0].Kind != EventMigrationStarted {
+		t.Fatalf("unexpected run events: %+v", own)
+	}
+	if len(*global) != 1 || (*global)[0].Kind != EventMigrationApplied {
+		t.Fatalf("unexpected package events: %+v", *global)
+	}
+}
+
+func TestReportManifestIssues(t *testing.T) {
+	var events []Event
+	reportManifestIssues(ObserverFunc(func(e Event) { events = append(events, e) }), []ManifestIssue{
+		{Type: IssueUntracked, Migration: "002_posts.sql"},
+		{Type: IssueHashMismatch, Migration: "003_backfill.go"},
+	})
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	if e := events[1]; e.Kind != EventManifestIssue || e.Version != "003_backfill" || e.Issue.Type != IssueHashMismatch {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+}
+
+func TestManifestIssuesReachRunObserver(t *testing.T) {
+	global := captureEvents(t)
+	dir := writePlanFixtures(t)
+	manifest := &ManifestLock{Migrations: []ManifestEntry{
+		{Name: "001_users.sql", SQLSHA256: "edited", ChecksumVersion: DefaultChecksumAlgorithm},
+	}}
+	if err := saveManifest(filepath.Join(dir, manifestFile), manifest); err != nil {
+		t.Fatalf("saveManifest: %v", err)
+	}
+	var own []Event
+	opts := MigrateOptions{Observer: ObserverFunc(func(e Event) { own = append(own, e) })}
+
+	if err := UpWithOptions(openTestDB(t), dir, opts); err == nil {
+		t.Fatalf("expected manifest validation to fail")
+	}
+	var issues int
+	for _, e := range own {
+		if e.Kind == EventManifestIssue {
+			issues++
+		}
+	}
+	if issues != 3 {
+		t.Fatalf("expected the hash mismatch and two untracked files, got %+v", own)
+	}
+	if len(*global) != 0 {
+		t.Fatalf("unexpected package events: %+v", *global)
+	}
+}
+
+func TestSlogObserverLevels(t *testing.T) {
+	var buf bytes.Buffer
+	obs := SlogObserver(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	obs.OnEvent(Event{Kind: EventMigrationFailed, Version: "001_users", Err: errors.New("syntax error")})
+	obs.OnEvent(Event{Kind: EventManifestIssue, Issue: &ManifestIssue{Type: IssueUntracked, Migration: "002_posts.sql"}})
+
+	out := buf.String()
+	if !strings.Contains(out, "level=ERROR msg=migration_failed version=001_users") || !strings.Contains(out, `error="syntax error"`) {
+		t.Fatalf("unexpected failure log: %s", out)
+	}
+	if !strings.Contains(out, "level=WARN msg=manifest_issue issue=untracked_migration migration=002_posts.sql") {
+		t.Fatalf("unexpected issue log: %s", out)
+	}
+}
@@ -0,0 +1,702 @@
+package driftflow
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MigrationDirection tells whether a planned step applies or reverts a migration.
+type MigrationDirection string
+
+const (
+	DirectionUp   MigrationDirection = "up"
+	DirectionDown MigrationDirection = "down"
+)
+
+// PlannedMigration is one step the runner would execute. SQL holds the exact
+// Up or Down section that would be sent to the database; Go migrations have
+// no SQL and Go set instead.
+type PlannedMigration struct {
+	Version   string             `json:"version"`
+	File      string             `json:"file"`
+	Direction MigrationDirection `json:"direction"`
+	Batch     int                `json:"batch"`
+	Checksum  string             `json:"checksum"`
+	SQL       string             `json:"sql"`
+	// Statements is SQL split for the target dialect; they run one by one.
+	Statements []string `json:"statements"`
+	// NoTransaction is set by the notransaction marker option; the step then
+	// runs outside a transaction.
+	NoTransaction bool `json:"no_transaction,omitempty"`
+	Go            bool `json:"go,omitempty"`
+	// Repeatable marks a repeatable migration re-applied after a change.
+	Repeatable bool `json:"repeatable,omitempty"`
+
+	run       func(tx *gorm.DB) error // Up or Down of a Go migration
+	algorithm ChecksumAlgorithm       // of Checksum; zero for Go migrations
+}
+
+// source names where the step comes from, for error messages.
+func (p PlannedMigration) source() string {
+	if p.Go {
+		return "go migration " + p.Version
+	}
+	return p.File
+}
+
+// ErrOutOfOrder is returned when a pending migration is older than the latest
+// applied one and MigrateOptions.AllowOutOfOrder is not set.
+var ErrOutOfOrder = errors.New("applied migrations are not contiguous")
+
+// ErrIrreversible is returned when a rollback reaches a migration marked
+// "-- +migrate Irreversible" or with an empty Down section and
+// MigrateOptions.ForceIrreversible is not set.
+var ErrIrreversible = errors.New("migration is irreversible")
+
+// Plan returns the ordered steps that MigrateTo(targetVersion) would execute,
+// or Up when targetVersion is empty. Nothing is executed and no tables are
+// created.
+func Plan(db *gorm.DB, dir string, targetVersion string) ([]PlannedMigration, error) {
+	return PlanWithOptions(db, dir, targetVersion, MigrateOptions{})
+}
+
+// PlanWithOptions is Plan with explicit runner options.
+func PlanWithOptions(db *gorm.DB, dir string, targetVersion string, opts MigrateOptions) ([]PlannedMigration, error) {
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := checkSource(src, opts.observer()); err != nil {
+		return nil, err
+	}
+	state, err := loadMigrationState(db, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	if targetVersion == "" {
+		return state.planUp()
+	}
+	return state.planMigrateTo(targetVersion)
+}
+
+// PlanDownSteps returns the steps DownSteps(steps) would execute without
+// running them.
+func PlanDownSteps(db *gorm.DB, dir string, steps int) ([]PlannedMigration, error) {
+	return PlanDownStepsWithOptions(db, dir, steps, MigrateOptions{})
+}
+
+// PlanDownStepsWithOptions is PlanDownSteps with explicit runner options.
+func PlanDownStepsWithOptions(db *gorm.DB, dir string, steps int, opts MigrateOptions) ([]PlannedMigration, error) {
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := checkSource(src, opts.observer()); err != nil {
+		return nil, err
+	}
+	state, err := loadMigrationState(db, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	return state.planDownSteps(steps)
+}
+
+// migrationState joins the migration files of a source, the Go
+// migrations and the repeatable migrations with migrations_history.
+type migrationState struct {
+	src          migrationSource
+	versions     []string
+	files        map[string]string
+	goMigrations map[string]GoMigration
+	callbacks    migrationCallbacks
+
+	repeatables       []string // repeatable versions in apply order
+	repeatableFiles   map[string]string
+	repeatableApplied map[string]SchemaMigration
+
+	run          runInfo // set by execute
+	applied      map[string]SchemaMigration
+	appliedOrder []string // versions in the order they were recorded
+	nextBatch    int
+	dialect      string
+	opts         MigrateOptions
+	tables       MetaTables          // resolved opts.Tables
+	algorithm    ChecksumAlgorithm   // resolved opts.ChecksumAlgorithm
+	upgraded     []SchemaMigration   // rows moved to algorithm, see upgradeChecksums
+	adopted      []squashAdoption    // see adoptSquashed
+	deps         map[string][]string // DependsOn of each version, see loadDependencies
+}
+
+// loadMigrationState reads the migrations of src and the history of db.
+func loadMigrationState(db *gorm.DB, src migrationSource, opts MigrateOptions) (*migrationState, error) {
+	s, err := loadSourceState(src, db.Dialector.Name(), opts)
+	if err != nil {
+		return nil, err
+	}
+	history := s.tables.historyTable()
+	if !db.Migrator().HasTable(history) {
+		s.nextBatch = 1
+		return s, nil
+	}
+	var applied []SchemaMigration
+	if err := db.Table(history).Order("id asc").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	if err := s.loadHistory(applied); err != nil {
+		return nil, err
+	}
+
+	// nuevo batch = max(batch)+1
+	var lastBatch int
+	if err := db.Table(history).
+		Select("COALESCE(MAX(batch),0)").
+		Scan(&lastBatch).Error; err != nil {
+		return nil, fmt.Errorf("read last batch: %w", err)
+	}
+	s.nextBatch = lastBatch + 1
+	return s, nil
+}
+
+// loadSourceState reads the migrations of src for dialect: the files and Go
+// migrations in dependency order, the callbacks and the repeatables. The
+// history is left empty.
+func loadSourceState(src migrationSource, dialect string, opts MigrateOptions) (*migrationState, error) {
+	if err := opts.checksumAlgorithm().check(); err != nil {
+		return nil, err
+	}
+	files, err := readMigrationFiles(src)
+	if err != nil {
+		return nil, err
+	}
+	s := &migrationState{
+		src:          src,
+		files:        make(map[string]string, len(files)),
+		goMigrations: goMigrationMap(src.goMigrations),
+		applied:      map[string]SchemaMigration{},
+		dialect:      dialect,
+		opts:         opts,
+		tables:       opts.tables(),
+		algorithm:    opts.checksumAlgorithm(),
+
+		repeatableFiles:   map[string]string{},
+		repeatableApplied: map[string]SchemaMigration{},
+	}
+	for _, f := range files {
+		version := migrationVersionFromFilename(f)
+		if _, ok := s.goMigrations[version]; ok {
+			return nil, fmt.Errorf("go migration %s conflicts with %s", version, f)
+		}
+		s.files[version] = f
+		s.versions = append(s.versions, version)
+	}
+	s.versions = mergeGoVersions(s.versions, s.goMigrations)
+	if err := s.loadDependencies(); err != nil {
+		return nil, err
+	}
+	if s.callbacks, err = loadMigrationCallbacks(src, s.dialect); err != nil {
+		return nil, err
+	}
+	repeatables, err := readRepeatableFiles(src)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range repeatables {
+		version := migrationVersionFromFilename(f)
+		s.repeatableFiles[version] = f
+		s.repeatables = append(s.repeatables, version)
+	}
+	return s, nil
+}
+
+// loadHistory records the history rows, in the order they were applied, then
+// upgrades their checksums and adopts squashed baselines.
+func (s *migrationState) loadHistory(rows []SchemaMigration) error {
+	for _, m := range rows {
+		if isRepeatableVersion(m.Version) {
+			s.repeatableApplied[m.Version] = m
+			continue
+		}
+		s.applied[m.Version] = m
+		s.appliedOrder = append(s.appliedOrder, m.Version)
+	}
+	if err := s.upgradeChecksums(); err != nil {
+		return err
+	}
+	return s.adoptSquashed()
+}
+
+// known reports whether version exists on disk or as a Go migration of the source.
+func (s *migrationState) known(version string) bool {
+	if _, ok := s.files[version]; ok {
+		return true
+	}
+	_, ok := s.goMigrations[version]
+	return ok
+}
+
+// checksum returns the current checksum of version: the file hash for .sql
+// migrations, the code-supplied one for Go migrations.
+func (s *migrationState) checksum(version string) (string, error) {
+	if m, ok := s.goMigrations[version]; ok {
+		return m.Checksum, nil
+	}
+	_, checksum, err := readMigrationScript(s.src, s.files[version], s.algorithm)
+	return checksum, err
+}
+
+// checksumVersion returns the algorithm of checksum(version), zero for Go
+// migrations.
+func (s *migrationState) checksumVersion(version string) ChecksumAlgorithm {
+	if _, ok := s.goMigrations[version]; ok {
+		return 0
+	}
+	return s.algorithm
+}
+
+func (s *migrationState) upStep(version string) (PlannedMigration, error) {
+	if m, ok := s.goMigrations[version]; ok {
+		return PlannedMigration{
+			Version:       version,
+			Direction:     DirectionUp,
+			Batch:         s.nextBatch,
+			Checksum:      m.Checksum,
+			NoTransaction: m.NoTransaction,
+			Go:            true,
+			run:           m.Up,
+		}, nil
+	}
+	file := s.files[version]
+	script, checksum, err := readMigrationScript(s.src, file, s.algorithm)
+	if err != nil {
+		return PlannedMigration{}, err
+	}
+	sql, noTx := script.sql("up", s.opts.environment(), s.dialect)
+	stmts, err := splitSQLStatements(sql, s.dialect)
+	if err != nil {
+		return PlannedMigration{}, fmt.Errorf("%s: %w", file, err)
+	}
+	return PlannedMigration{
+		Version:       version,
+		File:          file,
+		Direction:     DirectionUp,
+		Batch:         s.nextBatch,
+		Checksum:      checksum,
+		algorithm:     s.algorithm,
+		SQL:           sql,
+		Statements:    stmts,
+		NoTransaction: noTx,
+	}, nil
+}
+
+func (s *migrationState) downStep(version string) (PlannedMigration, error) {
+	applied, ok := s.applied[version]
+	if !ok {
+		return PlannedMigration{}, fmt.Errorf("applied migration missing from db: %s", version)
+	}
+	if m, ok := s.goMigrations[version]; ok {
+		if applied.Checksum != m.Checksum {
+			return PlannedMigration{}, fmt.Errorf("migration modified after applied: %s", version)
+		}
+		if m.Down == nil {
+			return PlannedMigration{}, fmt.Errorf("go migration %s has no Down function", version)
+		}
+		return PlannedMigration{
+			Version:       version,
+			Direction:     DirectionDown,
+			Batch:         applied.Batch,
+			Checksum:      m.Checksum,
+			NoTransaction: m.NoTransaction,
+			Go:            true,
+			run:           m.Down,
+		}, nil
+	}
+	file, ok := s.files[version]
+	if !ok {
+		return PlannedMigration{}, fmt.Errorf("missing down file for %s", version)
+	}
+	script, checksum, err := readMigrationScript(s.src, file, s.algorithm)
+	if err != nil {
+		return PlannedMigration{}, err
+	}
+	if applied.Checksum != checksum {
+		return PlannedMigration{}, fmt.Errorf("migration modified after applied: %s", version)
+	}
+	env := s.opts.environment()
+	if script.irreversible(env, s.dialect) && !s.opts.ForceIrreversible {
+		return PlannedMigration{}, fmt.Errorf("%w: %s; use --force-irreversible to roll it back anyway", ErrIrreversible, version)
+	}
+	sql, noTx := script.sql("down", env, s.dialect)
+	stmts, err := splitSQLStatements(sql, s.dialect)
+	if err != nil {
+		return PlannedMigration{}, fmt.Errorf("%s: %w", file, err)
+	}
+	return PlannedMigration{
+		Version:       version,
+		File:          file,
+		Direction:     DirectionDown,
+		Batch:         applied.Batch,
+		Checksum:      checksum,
+		algorithm:     s.algorithm,
+		SQL:           sql,
+		Statements:    stmts,
+		NoTransaction: noTx,
+	}, nil
+}
+
+// lastAppliedIndex returns the index in s.versions of the newest applied
+// migration, or -1 if none is applied.
+func (s *migrationState) lastAppliedIndex() int {
+	last := -1
+	for i, v := range s.versions {
+		if _, ok := s.applied[v]; ok {
+			last = i
+		}
+	}
+	return last
+}
+
+// before reports whether version a runs before version b: by their position
+// in s.versions, which follows dependencies, or by name when either has no
+// file.
+func (s *migrationState) before(a, b string) bool {
+	i, j := slices.Index(s.versions, a), slices.Index(s.versions, b)
+	if i < 0 || j < 0 {
+		return a < b
+	}
+	return i < j
+}
+
+// checkOutOfOrder enforces the strict ordering policy: no pending migration
+// may be older than the newest applied one, unless it declares dependencies
+// that are all applied.
+func (s *migrationState) checkOutOfOrder() error {
+	if s.opts.AllowOutOfOrder {
+		return nil
+	}
+	last := s.lastAppliedIndex()
+	for i := 0; i < last; i++ {
+		v := s.versions[i]
+		if _, ok := s.applied[v]; ok || s.dependenciesApplied(v) {
+			continue
+		}
+		return fmt.Errorf("%w; missing %s", ErrOutOfOrder, v)
+	}
+	return nil
+}
+
+// verifyApplied fails if a migration changed after it was applied.
+func (s *migrationState) verifyApplied() error {
+	for _, version := range s.versions {
+		m, ok := s.applied[version]
+		if !ok {
+			continue
+		}
+		checksum, err := s.checksum(version)
+		if err != nil {
+			return err
+		}
+		if m.Checksum != checksum {
+			return fmt.Errorf("migration modified after applied: %s", version)
+		}
+	}
+	return nil
+}
+
+func (s *migrationState) planUp() ([]PlannedMigration, error) {
+	if err := s.checkOutOfOrder(); err != nil {
+		return nil, err
+	}
+	if err := s.verifyApplied(); err != nil {
+		return nil, err
+	}
+	var steps []PlannedMigration
+	for _, version := range s.versions {
+		if _, ok := s.applied[version]; ok {
+			continue
+		}
+		step, err := s.upStep(version)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	if err := s.checkDependencies(steps); err != nil {
+		return nil, err
+	}
+	repeatables, err := s.planRepeatables()
+	if err != nil {
+		return nil, err
+	}
+	return append(steps, repeatables...), nil
+}
+
+func (s *migrationState) planDownSteps(steps int) ([]PlannedMigration, error) {
+	var appliedOrdered []string
+	for _, v := range s.versions {
+		if _, ok := s.applied[v]; ok {
+			appliedOrdered = append(appliedOrdered, v)
+		}
+	}
+	if steps < 1 || steps > len(appliedOrdered) {
+		steps = len(appliedOrdered)
+	}
+	plan := make([]PlannedMigration, 0, steps)
+	for i := 0; i < steps; i++ {
+		step, err := s.downStep(appliedOrdered[len(appliedOrdered)-1-i])
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, step)
+	}
+	if err := s.checkDependencies(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (s *migrationState) planMigrateTo(targetVersion string) ([]PlannedMigration, error) {
+	targetIndex := -1
+	for i, v := range s.versions {
+		if v == targetVersion {
+			targetIndex = i
+			break
+		}
+	}
+	if targetIndex == -1 {
+		return nil, fmt.Errorf("target version not found: %s", targetVersion)
+	}
+
+	for version := range s.applied {
+		if !s.known(version) {
+			return nil, fmt.Errorf("applied migration missing from disk: %s", version)
+		}
+	}
+
+	if err := s.checkOutOfOrder(); err != nil {
+		return nil, err
+	}
+	if err := s.verifyApplied(); err != nil {
+		return nil, err
+	}
+
+	// Roll back everything applied above the target, newest first, then
+	// apply everything pending up to it. Under the strict policy only one of
+	// the two loops produces steps.
+	var plan []PlannedMigration
+	for i := s.lastAppliedIndex(); i > targetIndex; i-- {
+		if _, ok := s.applied[s.versions[i]]; !ok {
+			continue
+		}
+		step, err := s.downStep(s.versions[i])
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, step)
+	}
+	for i := 0; i <= targetIndex; i++ {
+		if _, ok := s.applied[s.versions[i]]; ok {
+			continue
+		}
+		step, err := s.upStep(s.versions[i])
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, step)
+	}
+	if err := s.checkDependencies(plan); err != nil {
+		return nil, err
+	}
+	// repeatables describe the latest schema, so they only follow a run that
+	// ends at the newest migration
+	if targetIndex == len(s.versions)-1 {
+		repeatables, err := s.planRepeatables()
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, repeatables...)
+	}
+	return plan, nil
+}
+
+// execute runs the planned steps in order with the session timeouts of
+// s.opts applied, wrapped by the callback files and the configured hooks. A
+// cancelled context stops the run before the next step. The history changes
+// made while loading the state are saved first.
+func (s *migrationState) execute(db *gorm.DB, plan []PlannedMigration) error {
+	if err := s.saveHistoryUpgrades(db); err != nil {
+		return err
+	}
+	if len(plan) == 0 {
+		return nil
+	}
+	hooks := s.opts.hooks()
+	return withSessionTimeouts(db, s.opts, func(conn *gorm.DB) error {
+		s.run = loadRunInfo(conn, s.opts.observer())
+		fail := func(step PlannedMigration, err error) error {
+			hooks.OnError(conn, step, err)
+			return err
+		}
+		if err := execStatements(conn, s.callbacks.beforeAll, s.opts); err != nil {
+			return fail(PlannedMigration{}, fmt.Errorf("%s: %w", beforeMigrateCallback, err))
+		}
+		if err := hooks.BeforeAll(conn, plan); err != nil {
+			return fail(PlannedMigration{}, err)
+		}
+		ctx := dbContext(conn)
+		for _, step := range plan {
+			if err := ctx.Err(); err != nil {
+				return fail(step, err)
+			}
+			var err error
+			switch step.Direction {
+			case DirectionUp:
+				err = s.applyMigration(conn, step, hooks)
+			case DirectionDown:
+				err = s.revertMigration(conn, step, hooks)
+			}
+			if err != nil {
+				return fail(step, err)
+			}
+		}
+		if err := hooks.AfterAll(conn, plan); err != nil {
+			return fail(PlannedMigration{}, err)
+		}
+		if err := execStatements(conn, s.callbacks.afterAll, s.opts); err != nil {
+			return fail(PlannedMigration{}, fmt.Errorf("%s: %w", afterMigrateCallback, err))
+		}
+		return nil
+	})
+}
+
+// saveHistoryUpgrades writes the history changes loadMigrationState made in
+// memory: checksums moved to the current algorithm and adopted squashed
+// baselines.
+func (s *migrationState) saveHistoryUpgrades(db *gorm.DB) error {
+	if err := s.saveChecksumUpgrades(db); err != nil {
+		return err
+	}
+	return s.saveSquashAdoptions(db)
+}
+
+// runStepWithHooks runs step between BeforeEach and the afterEachMigrate.sql
+// callback and AfterEach, all on tx.
+func (s *migrationState) runStepWithHooks(tx *gorm.DB, step PlannedMigration, hooks MigrationHooks) error {
+	if err := hooks.BeforeEach(tx, step); err != nil {
+		return err
+	}
+	verb := "apply"
+	if step.Direction == DirectionDown {
+		verb = "revert"
+	}
+	if err := runStep(tx, step, s.opts); err != nil {
+		return fmt.Errorf("%s %s: %w", verb, step.source(), err)
+	}
+	if err := execStatements(tx, s.callbacks.afterEach, s.opts); err != nil {
+		return fmt.Errorf("%s after %s: %w", afterEachMigrateCallback, step.Version, err)
+	}
+	return hooks.AfterEach(tx, step)
+}
+
+// runStep executes the body of step: the Go function of a Go migration or its
+// SQL statements.
+func runStep(tx *gorm.DB, step PlannedMigration, opts MigrateOptions) error {
+	if step.run != nil {
+		return step.run(tx)
+	}
+	return execStatements(tx, step.Statements, opts)
+}
+
+// execStatements runs stmts in order and reports the first failure as a
+// *StatementError.
+func execStatements(db *gorm.DB, stmts []string, opts MigrateOptions) error {
+	for i, stmt := range stmts {
+		stmtDB, cancel := statementContext(db, opts)
+		err := stmtDB.Exec(stmt).Error
+		cancel()
+		if err != nil {
+			return &StatementError{Index: i + 1, SQL: stmt, Err: err}
+		}
+	}
+	return nil
+}
+
+// inTransaction runs fn inside a transaction unless noTransaction is set, in
+// which case fn receives db directly.
+func inTransaction(db *gorm.DB, noTransaction bool, fn func(tx *gorm.DB) error) error {
+	if noTransaction {
+		return fn(db)
+	}
+	return db.Transaction(fn)
+}
+
+func (s *migrationState) applyMigration(db *gorm.DB, step PlannedMigration, hooks MigrationHooks) error {
+	started := s.started(step)
+	// ✅ aplicar en transacción (salvo notransaction)
+	if err := inTransaction(db, step.NoTransaction, func(tx *gorm.DB) error {
+		if err := s.runStepWithHooks(tx, step, hooks); err != nil {
+			return err
+		}
+		rec := s.run.historyRow(step, time.Since(started))
+		if step.Repeatable {
+			// a repeatable keeps one row holding its last applied checksum
+			if err := removeMigration(tx, s.tables.historyTable(), step.Version); err != nil {
+				return err
+			}
+		}
+		return tx.Table(s.tables.historyTable()).Create(&rec).Error
+	}); err != nil {
+		s.failed(db, step, started, err)
+		return err
+	}
+	s.finished(step, EventMigrationApplied, started, nil)
+	s.audit(db, step.Version, "apply")
+	return nil
+}
+
+func (s *migrationState) revertMigration(db *gorm.DB, step PlannedMigration, hooks MigrationHooks) error {
+	started := s.started(step)
+	// the Down section gets the same atomicity as Up
+	if err := inTransaction(db, step.NoTransaction, func(tx *gorm.DB) error {
+		if err := s.runStepWithHooks(tx, step, hooks); err != nil {
+			return err
+		}
+		return removeMigration(tx, s.tables.historyTable(), step.Version)
+	}); err != nil {
+		s.failed(db, step, started, err)
+		return err
+	}
+	s.finished(step, EventMigrationReverted, started, nil)
+	s.audit(db, step.Version, "rollback")
+	return nil
+}
+
+// started reports the start of step and returns the start time.
+func (s *migrationState) started(step PlannedMigration) time.Time {
+	s.opts.observer().OnEvent(Event{Kind: EventMigrationStarted, Version: step.Version, Direction: step.Direction, File: step.File})
+	return time.Now()
+}
+
+// finished reports the outcome of step.
+func (s *migrationState) finished(step PlannedMigration, kind EventKind, started time.Time, err error) {
+	s.opts.observer().OnEvent(Event{
+		Kind:      kind,
+		Version:   step.Version,
+		Direction: step.Direction,
+		File:      step.File,
+		Duration:  time.Since(started),
+		Err:       err,
+	})
+}
+
+// failed records the failed attempt in the failures table and reports it. The write happens outside the rolled-back transaction.
+func (s *migrationState) failed(db *gorm.DB, step PlannedMigration, started time.Time, err error) {
+	duration := time.Since(started)
+	s.finished(step, EventMigrationFailed, started, err)
+	row := s.run.failureRow(step, duration, err)
+	// still record attempts aborted by a cancelled context
+	db = uncancelled(db)
+	reportAuditError(s.opts.observer(), step.Version, "record failure", db.Table(s.tables.failuresTable()).Create(&row).Error)
+}
+
+// audit writes an audit entry, reporting failures to the run's observer.
+func (s *migrationState) audit(db *gorm.DB, version, action string) {
+	reportAuditError(s.opts.observer(), version, action, writeAuditEvent(db, s.tables, version, action))
+}
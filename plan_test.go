@@ -0,0 +1,269 @@
+package driftflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openTestDB opens an in-memory sqlite database for tests that run
+// migrations end to end.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// newTestState loads the migrations in dir and records applied as the
+// history.
+func newTestState(t *testing.T, dir string, applied ...string) *migrationState {
+	t.Helper()
+	return newSourceState(t, dirSource(dir), applied...)
+}
+
+// newSourceState is newTestState for any migration source, including its Go
+// migrations. It runs the loader of migration runs, with applied recorded in
+// batch 1 with their current checksums.
+func newSourceState(t *testing.T, src migrationSource, applied ...string) *migrationState {
+	t.Helper()
+	s, err := loadSourceState(src, "", MigrateOptions{})
+	if err != nil {
+		t.Fatalf("loadSourceState: %v", err)
+	}
+	rows := make([]SchemaMigration, 0, len(applied))
+	for _, v := range applied {
+		checksum, err := s.checksum(v)
+		if err != nil {
+			t.Fatalf("checksum %s: %v", v, err)
+		}
+		rows = append(rows, SchemaMigration{Version: v, Batch: 1, Checksum: checksum, ChecksumVersion: s.checksumVersion(v)})
+	}
+	if err := s.loadHistory(rows); err != nil {
+		t.Fatalf("loadHistory: %v", err)
+	}
+	s.nextBatch = 2
+	return s
+}
+
+func writePlanFixtures(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeMigration(t, dir, "001_users", "CREATE TABLE users(id int);", "DROP TABLE users;")
+	writeMigration(t, dir, "002_posts", "CREATE TABLE posts(id int);", "DROP TABLE posts;")
+	writeMigration(t, dir, "003_tags", "CREATE TABLE tags(id int);", "DROP TABLE tags;")
+	return dir
+}
+
+func TestPlanUpSkipsApplied(t *testing.T) {
+	dir := writePlanFixtures(t)
+	plan, err := newTestState(t, dir, "001_users").planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if len(plan) != 2 || plan[0].Version != "002_posts" || plan[1].Version != "003_tags" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if plan[0].Direction != DirectionUp || plan[0].Batch != 2 || plan[0].SQL != "CREATE TABLE posts(id int);" {
+		t.Fatalf("unexpected step: %+v", plan[0])
+	}
+}
+
+func TestPlanUpDetectsModifiedMigration(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users")
+	m := s.applied["001_users"]
+	m.Checksum = "other"
+	s.applied["001_users"] = m
+	if _, err := s.planUp(); err == nil || !strings.Contains(err.Error(), "modified after applied") {
+		t.Fatalf("expected modified error, got %v", err)
+	}
+}
+
+func TestPlanMigrateToRollsBackInReverse(t *testing.T) {
+	dir := writePlanFixtures(t)
+	plan, err := newTestState(t, dir, "001_users", "002_posts", "003_tags").planMigrateTo("001_users")
+	if err != nil {
+		t.Fatalf("planMigrateTo: %v", err)
+	}
+	if len(plan) != 2 || plan[0].Version != "003_tags" || plan[1].Version != "002_posts" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if plan[0].Direction != DirectionDown || plan[0].Batch != 1 || plan[0].SQL != "DROP TABLE tags;" {
+		t.Fatalf("unexpected step: %+v", plan[0])
+	}
+}
+
+func TestPlanMigrateToRejectsGap(t *testing.T) {
+	dir := writePlanFixtures(t)
+	if _, err := newTestState(t, dir, "001_users", "003_tags").planMigrateTo("003_tags"); err == nil {
+		t.Fatalf("expected contiguity error")
+	}
+}
+
+func TestPlanDownSteps(t *testing.T) {
+	dir := writePlanFixtures(t)
+	plan, err := newTestState(t, dir, "001_users", "002_posts").planDownSteps(1)
+	if err != nil {
+		t.Fatalf("planDownSteps: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Version != "002_posts" || plan[0].Direction != DirectionDown {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+}
+
+func TestPlanUpStrictRejectsOutOfOrder(t *testing.T) {
+	dir := writePlanFixtures(t)
+	_, err := newTestState(t, dir, "001_users", "003_tags").planUp()
+	if !errors.Is(err, ErrOutOfOrder) || !strings.Contains(err.Error(), "002_posts") {
+		t.Fatalf("expected ErrOutOfOrder naming 002_posts, got %v", err)
+	}
+}
+
+func TestPlanAllowOutOfOrder(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users", "003_tags")
+	s.opts.AllowOutOfOrder = true
+
+	plan, err := s.planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Version != "002_posts" {
+		t.Fatalf("unexpected up plan: %+v", plan)
+	}
+
+	plan, err = s.planMigrateTo("002_posts")
+	if err != nil {
+		t.Fatalf("planMigrateTo: %v", err)
+	}
+	if len(plan) != 2 ||
+		plan[0].Version != "003_tags" || plan[0].Direction != DirectionDown ||
+		plan[1].Version != "002_posts" || plan[1].Direction != DirectionUp {
+		t.Fatalf("unexpected migrate-to plan: %+v", plan)
+	}
+}
+
+func TestPlanDownRefusesIrreversible(t *testing.T) {
+	dir := writePlanFixtures(t)
+	content := migrationIrreversibleMarker + "\n" + formatMigrationFile("UPDATE users SET id = id + 1;", "")
+	if err := os.WriteFile(filepath.Join(dir, "004_backfill.sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeMigration(t, dir, "005_trim", "UPDATE users SET id = id;", "")
+	s := newTestState(t, dir, "001_users", "002_posts", "003_tags", "004_backfill", "005_trim")
+
+	if _, err := s.planDownSteps(1); !errors.Is(err, ErrIrreversible) || !strings.Contains(err.Error(), "005_trim") {
+		t.Fatalf("expected an empty Down section to be irreversible, got %v", err)
+	}
+	if _, err := s.planMigrateTo("003_tags"); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("expected goto to refuse, got %v", err)
+	}
+
+	s.opts.ForceIrreversible = true
+	plan, err := s.planDownSteps(2)
+	if err != nil {
+		t.Fatalf("planDownSteps: %v", err)
+	}
+	if len(plan) != 2 || plan[1].Version != "004_backfill" || len(plan[1].Statements) != 0 {
+		t.Fatalf("unexpected forced plan: %+v", plan)
+	}
+}
+
+func TestPlanDownDialectFilteredDown(t *testing.T) {
+	dir := writePlanFixtures(t)
+	content := "-- +migrate Up\nCREATE INDEX idx_tags ON tags (id);\n\n-- +migrate Down dialect:postgres\nDROP INDEX idx_tags;\n"
+	if err := os.WriteFile(filepath.Join(dir, "004_index.sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestState(t, dir, "001_users", "002_posts", "003_tags", "004_index")
+
+	s.dialect = "postgres"
+	plan, err := s.planDownSteps(1)
+	if err != nil || len(plan) != 1 || len(plan[0].Statements) != 1 {
+		t.Fatalf("expected the postgres Down to run, got %+v, %v", plan, err)
+	}
+	s.dialect = "mysql"
+	if _, err := s.planDownSteps(1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("expected no Down on mysql to be irreversible, got %v", err)
+	}
+}
+
+func TestExecuteWritesHistory(t *testing.T) {
+	dir := writePlanFixtures(t)
+	db := openTestDB(t)
+	opts := MigrateOptions{}
+	if err := ensureMigrationsTable(db, opts.tables()); err != nil {
+		t.Fatalf("ensureMigrationsTable: %v", err)
+	}
+	load := func() *migrationState {
+		t.Helper()
+		s, err := loadMigrationState(db, dirSource(dir), opts)
+		if err != nil {
+			t.Fatalf("loadMigrationState: %v", err)
+		}
+		return s
+	}
+	history := func() []SchemaMigration {
+		t.Helper()
+		var rows []SchemaMigration
+		if err := db.Table(defaultHistoryTable).Order("id asc").Find(&rows).Error; err != nil {
+			t.Fatal(err)
+		}
+		return rows
+	}
+
+	s := load()
+	plan, err := s.planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if err := s.execute(db, plan); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	rows := history()
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 history rows, got %+v", rows)
+	}
+	for i, v := range []string{"001_users", "002_posts", "003_tags"} {
+		checksum, _ := s.checksum(v)
+		if m := rows[i]; m.Version != v || m.Batch != 1 || m.Checksum != checksum ||
+			m.ChecksumVersion != DefaultChecksumAlgorithm || m.Status != HistoryStatusApplied {
+			t.Fatalf("unexpected row %d: %+v", i, m)
+		}
+	}
+	if !db.Migrator().HasTable("tags") {
+		t.Fatalf("expected the migrations to run")
+	}
+
+	s = load()
+	if s.nextBatch != 2 || len(s.appliedOrder) != 3 {
+		t.Fatalf("expected the history to be read back, got batch %d, %v", s.nextBatch, s.appliedOrder)
+	}
+	plan, err = s.planDownSteps(1)
+	if err != nil {
+		t.Fatalf("planDownSteps: %v", err)
+	}
+	if err := s.execute(db, plan); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if rows := history(); len(rows) != 2 || rows[1].Version != "002_posts" {
+		t.Fatalf("expected 003_tags to be removed, got %+v", rows)
+	}
+	if db.Migrator().HasTable("tags") {
+		t.Fatalf("expected the rollback to run")
+	}
+}
@@ -0,0 +1,269 @@
+package driftflow
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HistoryAction is the kind of a manual change to migrations_history.
+type HistoryAction string
+
+const (
+	HistoryMarkApplied HistoryAction = "mark_applied"
+	HistoryMarkPending HistoryAction = "mark_pending"
+	HistoryRechecksum  HistoryAction = "rechecksum"
+)
+
+// HistoryChange is one row ForceVersion, MarkApplied, MarkPending or
+// Rechecksum changes. No migration is executed by any of them.
+type HistoryChange struct {
+	Version     string        `json:"version"`
+	Action      HistoryAction `json:"action"`
+	OldChecksum string        `json:"old_checksum,omitempty"`
+	NewChecksum string        `json:"new_checksum,omitempty"`
+}
+
+// HistoryOptions controls the history repair functions.
+type HistoryOptions struct {
+	MigrateOptions
+	// Reason explains the change. It is required and stored with every audit
+	// entry, next to the user and host that made it.
+	Reason string
+	// DryRun returns the changes without writing them.
+	DryRun bool
+}
+
+// ForceVersion makes the history say that every migration up to and
+// including version is applied and none after it: missing rows are recorded
+// as marked and later rows are removed. Use it after fixing a database by
+// hand.
+func ForceVersion(db *gorm.DB, dir string, version string, opts HistoryOptions) ([]HistoryChange, error) {
+	return repairHistory(db, dir, opts, nil, func(s *migrationState) ([]HistoryChange, error) {
+		return s.planForce(version)
+	})
+}
+
+// MarkApplied records versions as applied without running them.
+func MarkApplied(db *gorm.DB, dir string, versions []string, opts HistoryOptions) ([]HistoryChange, error) {
+	return repairHistory(db, dir, opts, nil, func(s *migrationState) ([]HistoryChange, error) {
+		return s.planMarkApplied(versions)
+	})
+}
+
+// MarkPending removes the history rows of versions without running their
+// Down sections. Versions whose file was deleted can be removed too.
+func MarkPending(db *gorm.DB, dir string, versions []string, opts HistoryOptions) ([]HistoryChange, error) {
+	return repairHistory(db, dir, opts, nil, func(s *migrationState) ([]HistoryChange, error) {
+		return s.planMarkPending(versions)
+	})
+}
+
+// Rechecksum stores the current checksum of applied versions, accepting an
+// edit made after they were applied (a fixed comment, say) that would
+// otherwise fail every run with "migration modified after applied". The
+// manifest entries of versions are signed again with the edited files.
+func Rechecksum(db *gorm.DB, dir string, versions []string, opts HistoryOptions) ([]HistoryChange, error) {
+	return repairHistory(db, dir, opts, versions, func(s *migrationState) ([]HistoryChange, error) {
+		return s.planRechecksum(versions)
+	})
+}
+
+// repairHistory plans a history change with plan and applies it in one
+// transaction under the migration lock, then audits every change. Manifest
+// hash mismatches of the resign versions are tolerated and their entries
+// are signed again once the history is written.
+func repairHistory(db *gorm.DB, dir string, opts HistoryOptions, resign []string, plan func(s *migrationState) ([]HistoryChange, error)) ([]HistoryChange, error) {
+	if opts.Reason == "" {
+		return nil, errors.New("a reason is required to change the migration history")
+	}
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := src.validate(); err != nil {
+		return nil, err
+	}
+	manifest, issues, err := checkManifest(src)
+	if err != nil {
+		return nil, err
+	}
+	resigned, issues := splitResignedIssues(issues, resign)
+	if err := manifestIssuesError(opts.observer(), issues); err != nil {
+		return nil, err
+	}
+	var changes []HistoryChange
+	err = withMigrationLock(db, opts.MigrateOptions, func(db *gorm.DB) error {
+		if err := ensureMigrationsTable(db, opts.tables()); err != nil {
+			return err
+		}
+		ensureAuditTables(db, opts.MigrateOptions, false)
+		state, err := loadMigrationState(db, src, opts.MigrateOptions)
+		if err != nil {
+			return err
+		}
+		if changes, err = plan(state); err != nil || opts.DryRun {
+			return err
+		}
+		if len(changes) > 0 {
+			state.run = loadRunInfo(db, opts.observer())
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				if err := state.saveHistoryUpgrades(tx); err != nil {
+					return err
+				}
+				return state.applyHistoryChanges(tx, changes)
+			}); err != nil {
+				return err
+			}
+		}
+		if len(resigned) > 0 {
+			if err := repairManifest(src, manifest, resigned, false); err != nil {
+				return err
+			}
+			if err := saveManifest(src.path(manifestFile), manifest); err != nil {
+				return err
+			}
+		}
+		for _, c := range changes {
+			detail := opts.Reason
+			if c.Action == HistoryRechecksum {
+				detail = fmt.Sprintf("%s (checksum %s -> %s)", opts.Reason, c.OldChecksum, c.NewChecksum)
+			}
+			reportAuditError(opts.observer(), c.Version, string(c.Action),
+				writeAuditEntry(db, state.tables, SchemaAuditLog{Version: c.Version, Action: "history_" + string(c.Action), Detail: detail}))
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// splitResignedIssues separates the hash mismatches of the versions in
+// resign from the other manifest issues.
+func splitResignedIssues(issues []ManifestIssue, resign []string) (resigned, rest []ManifestIssue) {
+	for _, is := range issues {
+		if is.Type == IssueHashMismatch && slices.Contains(resign, manifestEntryVersion(is.Migration)) {
+			resigned = append(resigned, is)
+			continue
+		}
+		rest = append(rest, is)
+	}
+	return resigned, rest
+}
+
+// applyHistoryChanges writes changes to the history table.
+func (s *migrationState) applyHistoryChanges(tx *gorm.DB, changes []HistoryChange) error {
+	history := s.tables.historyTable()
+	now := time.Now().UTC()
+	for _, c := range changes {
+		var err error
+		switch c.Action {
+		case HistoryMarkApplied:
+			err = tx.Table(history).Create(&SchemaMigration{
+				Version:          c.Version,
+				Batch:            s.nextBatch,
+				Checksum:         c.NewChecksum,
+				ChecksumVersion:  s.checksumVersion(c.Version),
+				AppliedAt:        now,
+				DBUser:           s.run.dbUser,
+				Host:             s.run.host,
+				DriftflowVersion: s.run.version,
+				Status:           HistoryStatusMarked,
+			}).Error
+		case HistoryMarkPending:
+			err = removeMigration(tx, history, c.Version)
+		case HistoryRechecksum:
+			err = tx.Table(history).Where("version = ?", c.Version).Updates(map[string]any{
+				"checksum":         c.NewChecksum,
+				"checksum_version": s.checksumVersion(c.Version),
+			}).Error
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.Action, c.Version, err)
+		}
+	}
+	return nil
+}
+
+func (s *migrationState) planMarkApplied(versions []string) ([]HistoryChange, error) {
+	var changes []HistoryChange
+	for _, v := range versions {
+		if !s.known(v) {
+			return nil, fmt.Errorf("migration not found: %s", v)
+		}
+		if _, ok := s.applied[v]; ok {
+			return nil, fmt.Errorf("migration already applied: %s", v)
+		}
+		checksum, err := s.checksum(v)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, HistoryChange{Version: v, Action: HistoryMarkApplied, NewChecksum: checksum})
+	}
+	return changes, nil
+}
+
+func (s *migrationState) planMarkPending(versions []string) ([]HistoryChange, error) {
+	var changes []HistoryChange
+	for _, v := range versions {
+		m, ok := s.applied[v]
+		if !ok {
+			return nil, fmt.Errorf("migration not applied: %s", v)
+		}
+		changes = append(changes, HistoryChange{Version: v, Action: HistoryMarkPending, OldChecksum: m.Checksum})
+	}
+	return changes, nil
+}
+
+func (s *migrationState) planRechecksum(versions []string) ([]HistoryChange, error) {
+	var changes []HistoryChange
+	for _, v := range versions {
+		m, ok := s.applied[v]
+		if !ok {
+			return nil, fmt.Errorf("migration not applied: %s", v)
+		}
+		if !s.known(v) {
+			return nil, fmt.Errorf("migration not found: %s", v)
+		}
+		checksum, err := s.checksum(v)
+		if err != nil {
+			return nil, err
+		}
+		if checksum == m.Checksum {
+			continue
+		}
+		changes = append(changes, HistoryChange{Version: v, Action: HistoryRechecksum, OldChecksum: m.Checksum, NewChecksum: checksum})
+	}
+	return changes, nil
+}
+
+// planForce marks every version up to target applied and every applied
+// version after it pending, newest first.
+func (s *migrationState) planForce(target string) ([]HistoryChange, error) {
+	if !s.known(target) {
+		return nil, fmt.Errorf("target version not found: %s", target)
+	}
+	var pending []string
+	for _, v := range s.versions {
+		if s.before(target, v) {
+			break
+		}
+		if _, ok := s.applied[v]; !ok {
+			pending = append(pending, v)
+		}
+	}
+	changes, err := s.planMarkApplied(pending)
+	if err != nil {
+		return nil, err
+	}
+	var later []string
+	for i := len(s.appliedOrder) - 1; i >= 0; i-- {
+		if v := s.appliedOrder[i]; s.before(target, v) {
+			later = append(later, v)
+		}
+	}
+	removed, err := s.planMarkPending(later)
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, removed...), nil
+}
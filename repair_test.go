@@ -0,0 +1,137 @@
+package driftflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanForce(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "002_posts", "003_tags")
+	s.applied["009_gone"] = SchemaMigration{Version: "009_gone", Checksum: "x"}
+	s.appliedOrder = append(s.appliedOrder, "009_gone")
+
+	changes, err := s.planForce("002_posts")
+	if err != nil {
+		t.Fatalf("planForce: %v", err)
+	}
+	want := []HistoryChange{
+		{Version: "001_users", Action: HistoryMarkApplied},
+		{Version: "009_gone", Action: HistoryMarkPending},
+		{Version: "003_tags", Action: HistoryMarkPending},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	for i, c := range changes {
+		if c.Version != want[i].Version || c.Action != want[i].Action {
+			t.Fatalf("change %d: expected %+v, got %+v", i, want[i], c)
+		}
+	}
+	if changes[0].NewChecksum == "" || changes[1].OldChecksum != "x" {
+		t.Fatalf("expected checksums on changes: %+v", changes)
+	}
+	if _, err := s.planForce("004_missing"); err == nil {
+		t.Fatalf("expected unknown target error")
+	}
+}
+
+func TestPlanMarkAppliedAndPending(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users")
+
+	if _, err := s.planMarkApplied([]string{"001_users"}); err == nil || !strings.Contains(err.Error(), "already applied") {
+		t.Fatalf("expected already applied error, got %v", err)
+	}
+	if _, err := s.planMarkApplied([]string{"004_missing"}); err == nil {
+		t.Fatalf("expected not found error")
+	}
+	changes, err := s.planMarkApplied([]string{"002_posts", "003_tags"})
+	if err != nil || len(changes) != 2 || changes[1].Version != "003_tags" {
+		t.Fatalf("unexpected mark-applied plan: %+v, %v", changes, err)
+	}
+
+	if _, err := s.planMarkPending([]string{"002_posts"}); err == nil || !strings.Contains(err.Error(), "not applied") {
+		t.Fatalf("expected not applied error, got %v", err)
+	}
+	changes, err = s.planMarkPending([]string{"001_users"})
+	if err != nil || len(changes) != 1 || changes[0].Action != HistoryMarkPending {
+		t.Fatalf("unexpected mark-pending plan: %+v, %v", changes, err)
+	}
+}
+
+func TestPlanRechecksum(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users", "002_posts")
+	m := s.applied["002_posts"]
+	m.Checksum = "old"
+	s.applied["002_posts"] = m
+
+	changes, err := s.planRechecksum([]string{"001_users", "002_posts"})
+	if err != nil {
+		t.Fatalf("planRechecksum: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Version != "002_posts" || changes[0].OldChecksum != "old" || changes[0].NewChecksum == "" {
+		t.Fatalf("expected only the edited migration, got %+v", changes)
+	}
+	if _, err := s.planRechecksum([]string{"003_tags"}); err == nil {
+		t.Fatalf("expected not applied error")
+	}
+}
+
+func TestRepairHistoryRequiresReason(t *testing.T) {
+	if _, err := MarkApplied(nil, t.TempDir(), []string{"001_users"}, HistoryOptions{}); err == nil || !strings.Contains(err.Error(), "reason") {
+		t.Fatalf("expected reason error, got %v", err)
+	}
+}
+
+func TestRechecksumResignsManifest(t *testing.T) {
+	dir := writePlanFixtures(t)
+	manifest := &ManifestLock{Migrations: []ManifestEntry{}}
+	for _, name := range []string{"001_users", "002_posts", "003_tags"} {
+		if err := appendMigrationToManifest(dir, manifest, name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			t.Fatalf("appendMigrationToManifest: %v", err)
+		}
+	}
+	if err := saveManifest(filepath.Join(dir, manifestFile), manifest); err != nil {
+		t.Fatalf("saveManifest: %v", err)
+	}
+	db := openTestDB(t)
+	if err := UpWithOptions(db, dir, MigrateOptions{}); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	path := filepath.Join(dir, "001_users.sql")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(b), "CREATE TABLE users(id int);", "-- users of the app\nCREATE TABLE users(id int);", 1)
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpWithOptions(db, dir, MigrateOptions{}); err == nil {
+		t.Fatalf("expected the edited migration to fail Up")
+	}
+
+	changes, err := Rechecksum(db, dir, []string{"001_users"}, HistoryOptions{Reason: "comment fixed"})
+	if err != nil {
+		t.Fatalf("Rechecksum: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Version != "001_users" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+	var row SchemaMigration
+	if err := db.Table(defaultHistoryTable).Where("version = ?", "001_users").First(&row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if row.Checksum != changes[0].NewChecksum {
+		t.Fatalf("history checksum not updated: %+v", row)
+	}
+	if err := UpWithOptions(db, dir, MigrateOptions{}); err != nil {
+		t.Fatalf("Up after Rechecksum: %v", err)
+	}
+}
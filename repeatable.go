@@ -0,0 +1,92 @@
+package driftflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// repeatablePrefix marks repeatable migrations, e.g. R__refresh_views.sql.
+// They have no version: Up re-applies them after the versioned migrations
+// whenever their checksum differs from the one in migrations_history. The
+// whole file is the body; there are no Up/Down sections.
+const repeatablePrefix = "R__"
+
+func isRepeatableFile(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), repeatablePrefix)
+}
+
+// isRepeatableVersion reports whether a history version belongs to a
+// repeatable migration.
+func isRepeatableVersion(version string) bool {
+	return strings.HasPrefix(version, repeatablePrefix)
+}
+
+// readRepeatableFiles returns the repeatable migration files in src sorted by
+// name, which is the order they are applied in.
+func readRepeatableFiles(src migrationSource) ([]string, error) {
+	files, err := src.glob(repeatablePrefix + "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readRepeatable returns the body of a repeatable migration and its checksum
+// under algorithm a.
+func readRepeatable(src migrationSource, path string, a ChecksumAlgorithm) (string, string, error) {
+	b, err := src.readFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	body := strings.TrimSpace(string(b))
+	if body == "" {
+		return "", "", fmt.Errorf("%s: empty repeatable migration", path)
+	}
+	checksum, err := repeatableChecksum(b, a)
+	return body, checksum, err
+}
+
+// repeatableStep plans version if its file changed since it was last applied.
+func (s *migrationState) repeatableStep(version string) (PlannedMigration, bool, error) {
+	file := s.repeatableFiles[version]
+	body, checksum, err := readRepeatable(s.src, file, s.algorithm)
+	if err != nil {
+		return PlannedMigration{}, false, err
+	}
+	if m, ok := s.repeatableApplied[version]; ok && m.Checksum == checksum {
+		return PlannedMigration{}, false, nil
+	}
+	stmts, err := splitSQLStatements(body, s.dialect)
+	if err != nil {
+		return PlannedMigration{}, false, fmt.Errorf("%s: %w", file, err)
+	}
+	return PlannedMigration{
+		Version:    version,
+		File:       file,
+		Direction:  DirectionUp,
+		Batch:      s.nextBatch,
+		Checksum:   checksum,
+		SQL:        body,
+		algorithm:  s.algorithm,
+		Statements: stmts,
+		Repeatable: true,
+	}, true, nil
+}
+
+// planRepeatables returns the repeatable migrations to re-apply, in name order.
+func (s *migrationState) planRepeatables() ([]PlannedMigration, error) {
+	var plan []PlannedMigration
+	for _, version := range s.repeatables {
+		step, changed, err := s.repeatableStep(version)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			plan = append(plan, step)
+		}
+	}
+	return plan, nil
+}
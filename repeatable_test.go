@@ -0,0 +1,101 @@
+package driftflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeRepeatable(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name+".sql")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestPlanUpReappliesChangedRepeatables(t *testing.T) {
+	dir := writePlanFixtures(t)
+	viewsPath := writeRepeatable(t, dir, "R__views", "CREATE OR REPLACE VIEW v AS SELECT 1;")
+	grantsPath := writeRepeatable(t, dir, "R__grants", "GRANT SELECT ON users TO app;")
+
+	files, err := readMigrationFiles(dirSource(dir))
+	if err != nil {
+		t.Fatalf("readMigrationFiles: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("repeatables must not be versioned migrations: %v", files)
+	}
+
+	s := newTestState(t, dir, "001_users", "002_posts", "003_tags")
+	_, grantsSum, err := readRepeatable(dirSource(dir), grantsPath, s.algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.repeatableApplied["R__grants"] = SchemaMigration{Version: "R__grants", Checksum: grantsSum}
+	s.repeatableApplied["R__views"] = SchemaMigration{Version: "R__views", Checksum: "old"}
+
+	plan, err := s.planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Version != "R__views" || !plan[0].Repeatable || plan[0].File != viewsPath {
+		t.Fatalf("expected only the changed repeatable, got %+v", plan)
+	}
+
+	rows, err := s.status(nil)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	states := map[string]MigrationState{}
+	for _, r := range rows {
+		states[r.Version] = r.State
+	}
+	if states["R__views"] != StatePending || states["R__grants"] != StateApplied {
+		t.Fatalf("unexpected repeatable states: %+v", states)
+	}
+}
+
+func TestPlanUpRunsRepeatablesAfterVersioned(t *testing.T) {
+	dir := writePlanFixtures(t)
+	writeRepeatable(t, dir, "R__views", "CREATE OR REPLACE VIEW v AS SELECT 1;")
+
+	plan, err := newTestState(t, dir, "001_users").planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if len(plan) != 3 || plan[2].Version != "R__views" {
+		t.Fatalf("expected repeatable last, got %+v", plan)
+	}
+}
+
+func TestValidateRepeatables(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "001_init", "CREATE TABLE t1(id int);", "DROP TABLE t1;")
+	writeRepeatable(t, dir, "R__views", "CREATE VIEW v1 AS SELECT id FROM t1;")
+	writeRepeatable(t, dir, "R__grants", "GRANT SELECT ON t1 TO app;")
+	if err := Validate(dir); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	writeRepeatable(t, dir, "R__empty", "  \n")
+	if err := Validate(dir); err == nil {
+		t.Fatalf("expected empty repeatable to be invalid")
+	}
+}
+
+func TestValidateManifestIgnoresRepeatableEdits(t *testing.T) {
+	dir := t.TempDir()
+	writeRepeatable(t, dir, "R__views", "CREATE VIEW v1 AS SELECT 1;")
+	writeRepeatable(t, dir, "R__grants", "GRANT SELECT ON t1 TO app;")
+
+	manifest := &ManifestLock{Migrations: []ManifestEntry{{Name: "R__views.sql", SQLSHA256: "stale"}}}
+	issues, err := validateManifest(dirSource(dir), manifest)
+	if err != nil {
+		t.Fatalf("validateManifest: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Type != IssueUntracked || issues[0].Migration != "R__grants.sql" {
+		t.Fatalf("expected only the untracked repeatable, got %+v", issues)
+	}
+}
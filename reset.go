@@ -1,6 +1,7 @@
 package driftflow
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -15,6 +16,9 @@ type ResetOptions struct {
 	Driver   string
 	Schema   string
 	Database string
+	// Tables names the history, audit and field history tables to drop, and
+	// the migration lock to clear, when they live outside the reset schema.
+	Tables MetaTables
 }
 
 // ResetSummary describes the outcome of a reset operation.
@@ -27,6 +31,11 @@ type ResetSummary struct {
 	RecreatedDatabase bool
 }
 
+// ResetContext is Reset bound to ctx.
+func ResetContext(ctx context.Context, db *gorm.DB, opts ResetOptions) (ResetSummary, error) {
+	return Reset(db.WithContext(ctx), opts)
+}
+
 // Reset removes all tables in the target database/schema based on the detected dialect.
 func Reset(db *gorm.DB, opts ResetOptions) (ResetSummary, error) {
 	dialect := strings.ToLower(db.Dialector.Name())
@@ -68,9 +77,31 @@ func Reset(db *gorm.DB, opts ResetOptions) (ResetSummary, error) {
 	if err != nil {
 		return ResetSummary{}, err
 	}
+	if err := dropExternalHistory(db, opts.Tables.resolve(), summary.Schema); err != nil {
+		return ResetSummary{}, err
+	}
 	return summary, nil
 }
 
+// dropExternalHistory drops the history, audit and field history tables of t
+// when they live in a schema other than the reset one: they describe tables
+// that no longer exist, and the next Up would otherwise skip every migration.
+// The lock table is shared by every migration set of that schema, so only the
+// lock rows of t are removed from it.
+func dropExternalHistory(db *gorm.DB, t MetaTables, schema string) error {
+	if t.Schema == "" || t.Schema == schema {
+		return nil
+	}
+	if err := db.Migrator().DropTable(t.historyTable(), t.failuresTable(), t.auditTable(), t.fieldHistoryTable()); err != nil {
+		return err
+	}
+	if !db.Migrator().HasTable(t.lockTable()) {
+		return nil
+	}
+	// tenant runs append ":<tenant>" to the lock name
+	return db.Table(t.lockTable()).Where("name = ? OR name LIKE ?", t.lockName(), t.lockName()+":%").Delete(&MigrationLock{}).Error
+}
+
 func defaultSchemaForDialect(dialect, database string) string {
 	switch dialect {
 	case "postgres":
@@ -1,6 +1,7 @@
 package driftflow
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -46,13 +48,21 @@ func GetSeeders() []Seeder {
 	return projectSeederRegistry()
 }
 
-// Seed executes the Seed method of each registered Seeder using files in dir.
-// File name is derived from the seeder type name in lower case with .seed.json
-// (e.g. BookmarkSeeder -> bookmark.seed.json).
 // Seed executes the Seed method of each registered Seeder using files in dir.
 // File name is derived from the seeder type name in lower case with .seed.json
 // (e.g. BookmarkSeeder -> bookmark.seed.json).
 func Seed(db *gorm.DB, dir string) error {
+	return SeedWithOptions(db, dir, MigrateOptions{})
+}
+
+// SeedContext is Seed bound to ctx; seeders receive a *gorm.DB carrying it.
+func SeedContext(ctx context.Context, db *gorm.DB, dir string) error {
+	return Seed(db.WithContext(ctx), dir)
+}
+
+// SeedWithOptions is Seed writing the audit entries to opts.MetaTables and
+// its events to opts.Observer.
+func SeedWithOptions(db *gorm.DB, dir string, opts MigrateOptions) error {
 	seeders := GetSeeders()
 	if len(seeders) == 0 {
 		// Important: driftflow should NOT require seeders.
@@ -65,7 +75,8 @@ func Seed(db *gorm.DB, dir string) error {
 	}
 
 	// Ensure audit table exists (use original db; fine).
-	_ = EnsureAuditTable(db)
+	ensureAuditTables(db, opts, false)
+	obs := opts.observer()
 
 	for _, s := range seeders {
 		t := reflect.TypeOf(s)
@@ -90,10 +101,12 @@ func Seed(db *gorm.DB, dir string) error {
 		// - prevents partial inserts per seeder
 		// - keeps audit record consistent with the insert
 		if err := cleanDB.Transaction(func(tx *gorm.DB) error {
+			started := time.Now()
 			if err := s.Seed(tx, path); err != nil {
 				return err
 			}
-			LogAuditEvent(tx, file, "seed")
+			obs.OnEvent(Event{Kind: EventSeedFileLoaded, File: path, Duration: time.Since(started)})
+			reportAuditError(obs, file, "seed", writeAuditEvent(tx, opts.tables(), file, "seed"))
 			return nil
 		}); err != nil {
 			return fmt.Errorf("seed %s failed: %w", file, err)
@@ -110,7 +123,7 @@ func SeedFromJSON(db *gorm.DB, dir string, models []interface{}) error {
 	if err := config.ValidateDir(dir); err != nil {
 		return err
 	}
-	_ = EnsureAuditTable(db)
+	ensureAuditTables(db, MigrateOptions{}, false)
 
 	for _, m := range models {
 		t := reflect.TypeOf(m)
@@ -143,6 +156,7 @@ func SeedFromJSON(db *gorm.DB, dir string, models []interface{}) error {
 		if err := db.Create(slicePtr.Elem().Interface()).Error; err != nil {
 			return fmt.Errorf("insert seed %s failed: %w", file, err)
 		}
+		notify(Event{Kind: EventSeedFileLoaded, File: path})
 
 		LogAuditEvent(db, file, "seed")
 	}
@@ -213,7 +227,7 @@ func SeedFromJSON(db *gorm.DB, dir string, models []interface{}) error {
 	if err := config.ValidateDir(dir); err != nil {
 		return err
 	}
-	_ = EnsureAuditTable(db)
+	ensureAuditTables(db, MigrateOptions{}, false)
 	for _, m := range models {
 		t := reflect.TypeOf(m)
 		if t.Kind() == reflect.Pointer {
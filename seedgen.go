@@ -440,7 +440,7 @@ func resolveSeedGenDirs(dir string) (string, string, error) {
 		dir = cfg.SeedGenDir
 		if strings.TrimSpace(dir) == "" {
 			dir = "internal/database/data"
-			fmt.Println("No se definió 'SEED_GEN_DIR', se usará ruta por defecto:", dir)
+			notify(Event{Kind: EventNotice, File: dir, Message: "No se definió 'SEED_GEN_DIR', se usará ruta por defecto"})
 		}
 	}
 	baseDir := dir
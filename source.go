@@ -0,0 +1,89 @@
+package driftflow
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/misaelcrespo30/DriftFlow/config"
+)
+
+// migrationSource is where a run reads its migrations from: a directory on
+// disk or an fs.FS, typically an embed.FS. Paths returned by glob are valid
+// arguments to readFile and are what PlannedMigration.File reports. The Go
+// migrations of the set travel with it; a source without them skips the Go
+// entries of its manifest, which it has no code for.
+type migrationSource struct {
+	dir          string
+	fsys         fs.FS
+	goMigrations []GoMigration
+}
+
+func dirSource(dir string) migrationSource {
+	return migrationSource{dir: dir}
+}
+
+func fsSource(fsys fs.FS) migrationSource {
+	return migrationSource{fsys: fsys}
+}
+
+// withGoMigrations returns s with the Go migrations of its set.
+func (s migrationSource) withGoMigrations(gos []GoMigration) migrationSource {
+	s.goMigrations = gos
+	return s
+}
+
+// validate checks that the source exists and is a directory and that its Go
+// migrations are valid.
+func (s migrationSource) validate() error {
+	if err := checkGoMigrations(s.goMigrations); err != nil {
+		return err
+	}
+	if s.fsys == nil {
+		if s.dir == "" {
+			return errors.New("no migrations directory or fs.FS given")
+		}
+		return config.ValidateDir(s.dir)
+	}
+	info, err := fs.Stat(s.fsys, ".")
+	if err != nil {
+		return fmt.Errorf("migrations fs: %w", err)
+	}
+	if !info.IsDir() {
+		return errors.New("migrations fs: root is not a directory")
+	}
+	return nil
+}
+
+// path returns the path of name inside the source.
+func (s migrationSource) path(name string) string {
+	if s.fsys == nil {
+		return filepath.Join(s.dir, name)
+	}
+	return name
+}
+
+func (s migrationSource) readFile(path string) ([]byte, error) {
+	if s.fsys == nil {
+		return os.ReadFile(path)
+	}
+	return fs.ReadFile(s.fsys, path)
+}
+
+// glob returns the paths of the files at the top level of the source that
+// match pattern.
+func (s migrationSource) glob(pattern string) ([]string, error) {
+	if s.fsys == nil {
+		return filepath.Glob(filepath.Join(s.dir, pattern))
+	}
+	return fs.Glob(s.fsys, pattern)
+}
+
+func (s migrationSource) readDir() ([]fs.DirEntry, error) {
+	if s.fsys == nil {
+		return os.ReadDir(s.dir)
+	}
+	return fs.ReadDir(s.fsys, ".")
+}
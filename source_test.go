@@ -0,0 +1,82 @@
+package driftflow
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// embeddedMigrations returns an in-memory migrations tree with a manifest
+// matching its files, like one shipped with //go:embed.
+func embeddedMigrations(t *testing.T) fstest.MapFS {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"001_users.sql": {Data: []byte(formatMigrationFile("CREATE TABLE users(id int);", "DROP TABLE users;"))},
+		"002_posts.sql": {Data: []byte(formatMigrationFile("CREATE TABLE posts(id int);", "DROP TABLE posts;"))},
+	}
+	manifest := ManifestLock{Version: 1}
+	for _, name := range []string{"001_users.sql", "002_posts.sql"} {
+		manifest.Migrations = append(manifest.Migrations, ManifestEntry{Name: name, SQLSHA256: sha256Hex(fsys[name].Data)})
+	}
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("marshal manifest: %v", err)
+	}
+	fsys[manifestFile] = &fstest.MapFile{Data: b}
+	return fsys
+}
+
+func TestValidateFS(t *testing.T) {
+	fsys := embeddedMigrations(t)
+	if err := ValidateFS(fsys); err != nil {
+		t.Fatalf("ValidateFS: %v", err)
+	}
+
+	fsys["002_posts.sql"] = &fstest.MapFile{Data: []byte(formatMigrationFile("CREATE TABLE posts(id bigint);", "DROP TABLE posts;"))}
+	err := ValidateFS(fsys)
+	if err == nil || !strings.Contains(err.Error(), string(IssueHashMismatch)) || !strings.Contains(err.Error(), "002_posts.sql") {
+		t.Fatalf("expected hash mismatch for 002_posts.sql, got %v", err)
+	}
+
+	delete(fsys, manifestFile)
+	if err := ValidateFS(fsys); err == nil || !strings.Contains(err.Error(), string(IssueUntracked)) {
+		t.Fatalf("expected untracked migrations without manifest, got %v", err)
+	}
+}
+
+func TestValidateFSInvalidMigration(t *testing.T) {
+	fsys := embeddedMigrations(t)
+	fsys["003_broken.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE broken(id int);")}
+	if err := ValidateFS(fsys); err == nil || !strings.Contains(err.Error(), "003_broken") {
+		t.Fatalf("expected invalid migration error, got %v", err)
+	}
+}
+
+func TestUpFSRejectsNilFS(t *testing.T) {
+	if err := UpFS(nil, nil, MigrateOptions{}); err == nil {
+		t.Fatal("expected error for nil fs.FS")
+	}
+}
+
+func TestPlanFromFS(t *testing.T) {
+	s := newSourceState(t, fsSource(embeddedMigrations(t)), "001_users")
+	plan, err := s.planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Version != "002_posts" || plan[0].File != "002_posts.sql" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if len(plan[0].Statements) != 1 || plan[0].Statements[0] != "CREATE TABLE posts(id int);" {
+		t.Fatalf("unexpected statements: %q", plan[0].Statements)
+	}
+
+	down, err := s.planDownSteps(1)
+	if err != nil {
+		t.Fatalf("planDownSteps: %v", err)
+	}
+	if len(down) != 1 || down[0].Version != "001_users" || down[0].SQL != "DROP TABLE users;" {
+		t.Fatalf("unexpected down plan: %+v", down)
+	}
+}
@@ -0,0 +1,224 @@
+package driftflow
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	statementBeginMarker = "-- +migrate StatementBegin"
+	statementEndMarker   = "-- +migrate StatementEnd"
+)
+
+var dollarTagPattern = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+
+// StatementError reports which statement of a migration section failed.
+type StatementError struct {
+	Index int // 1-based position of the statement in its section
+	SQL   string
+	Err   error
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d: %v\n%s", e.Index, e.Err, e.SQL)
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// splitSQLStatements splits a migration section into statements that can be
+// executed one by one. It understands quoted strings and identifiers, line and
+// block comments, Postgres dollar-quoting, SQL Server GO batch separators and
+// explicit StatementBegin/StatementEnd blocks. On SQL Server a statement is a
+// whole GO batch; elsewhere statements end at a top-level semicolon.
+func splitSQLStatements(sql, dialect string) ([]string, error) {
+	sp := sqlSplitter{dialect: normalizeEngine(dialect)}
+	var (
+		inBlock    bool
+		blockLines []string
+	)
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inBlock {
+			if trimmed == statementEndMarker {
+				if stmt := strings.TrimSpace(strings.Join(blockLines, "\n")); stmt != "" {
+					sp.stmts = append(sp.stmts, stmt)
+				}
+				inBlock = false
+				blockLines = nil
+				continue
+			}
+			if trimmed == statementBeginMarker {
+				return nil, fmt.Errorf("nested %s", statementBeginMarker)
+			}
+			blockLines = append(blockLines, line)
+			continue
+		}
+		if sp.atTopLevel() {
+			switch {
+			case trimmed == statementBeginMarker:
+				sp.flush()
+				inBlock = true
+				continue
+			case trimmed == statementEndMarker:
+				return nil, fmt.Errorf("%s without %s", statementEndMarker, statementBeginMarker)
+			case sp.isMSSQL() && isGoSeparator(trimmed):
+				sp.flush()
+				continue
+			}
+		}
+		sp.scanLine(line)
+	}
+	if inBlock {
+		return nil, fmt.Errorf("%s without %s", statementBeginMarker, statementEndMarker)
+	}
+	switch {
+	case sp.quote != 0:
+		return nil, fmt.Errorf("unterminated quoted string or identifier")
+	case sp.dollarTag != "":
+		return nil, fmt.Errorf("unterminated dollar-quoted string %s", sp.dollarTag)
+	case sp.blockComment > 0:
+		return nil, fmt.Errorf("unterminated block comment")
+	}
+	sp.flush()
+	return sp.stmts, nil
+}
+
+type sqlSplitter struct {
+	dialect      string
+	buf          strings.Builder
+	hasCode      bool
+	stmts        []string
+	quote        rune   // closing rune of the quoted string or identifier we are in
+	dollarTag    string // closing $tag$ of the dollar-quoted string we are in
+	blockComment int
+}
+
+func (sp *sqlSplitter) isMSSQL() bool {
+	return sp.dialect == "sqlserver" || sp.dialect == "mssql"
+}
+
+func (sp *sqlSplitter) atTopLevel() bool {
+	return sp.quote == 0 && sp.dollarTag == "" && sp.blockComment == 0
+}
+
+func (sp *sqlSplitter) flush() {
+	stmt := strings.TrimSpace(sp.buf.String())
+	if sp.hasCode && stmt != "" {
+		sp.stmts = append(sp.stmts, stmt)
+	}
+	sp.buf.Reset()
+	sp.hasCode = false
+}
+
+func (sp *sqlSplitter) scanLine(line string) {
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case sp.blockComment > 0:
+			sp.buf.WriteRune(c)
+			if c == '*' && next == '/' {
+				sp.buf.WriteRune(next)
+				i++
+				sp.blockComment--
+			} else if c == '/' && next == '*' && sp.dialect == "postgres" {
+				sp.buf.WriteRune(next)
+				i++
+				sp.blockComment++
+			}
+			continue
+
+		case sp.quote != 0:
+			sp.buf.WriteRune(c)
+			if c == '\\' && sp.dialect == "mysql" && sp.quote != '`' && next != 0 {
+				sp.buf.WriteRune(next)
+				i++
+			} else if c == sp.quote {
+				if next == sp.quote {
+					// doubled quote is an escaped quote
+					sp.buf.WriteRune(next)
+					i++
+				} else {
+					sp.quote = 0
+				}
+			}
+			continue
+
+		case sp.dollarTag != "":
+			if strings.HasPrefix(string(runes[i:]), sp.dollarTag) {
+				sp.buf.WriteString(sp.dollarTag)
+				i += len([]rune(sp.dollarTag)) - 1
+				sp.dollarTag = ""
+			} else {
+				sp.buf.WriteRune(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '-' && next == '-', c == '#' && sp.dialect == "mysql":
+			sp.buf.WriteString(string(runes[i:]))
+			i = len(runes)
+		case c == '/' && next == '*':
+			sp.buf.WriteString("/*")
+			i++
+			sp.blockComment++
+		case c == '\'' || c == '"' || c == '`':
+			sp.quote = c
+			sp.hasCode = true
+			sp.buf.WriteRune(c)
+		case c == '[' && sp.isMSSQL():
+			sp.quote = ']'
+			sp.hasCode = true
+			sp.buf.WriteRune(c)
+		case c == '$' && sp.dialect == "postgres" && (i == 0 || !isIdentRune(runes[i-1])):
+			if tag := dollarTagPattern.FindString(string(runes[i:])); tag != "" {
+				sp.dollarTag = tag
+				sp.hasCode = true
+				sp.buf.WriteString(tag)
+				i += len([]rune(tag)) - 1
+			} else {
+				sp.buf.WriteRune(c)
+			}
+		case c == ';' && !sp.isMSSQL():
+			sp.buf.WriteRune(c)
+			sp.flush()
+		default:
+			sp.buf.WriteRune(c)
+			if !unicode.IsSpace(c) {
+				sp.hasCode = true
+			}
+		}
+	}
+	sp.buf.WriteRune('\n')
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isGoSeparator reports whether line is a SQL Server batch separator ("GO",
+// optionally followed by a repeat count, which is ignored).
+func isGoSeparator(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 2 || !strings.EqualFold(fields[0], "GO") {
+		return false
+	}
+	if len(fields) == 2 {
+		for _, r := range fields[1] {
+			if !unicode.IsDigit(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package driftflow
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitSQLStatementsSemicolons(t *testing.T) {
+	sql := "-- create tables\nCREATE TABLE a (note text default 'x;y');\nCREATE TABLE \"b;c\" (id int); /* trailing; comment */\n-- only a comment;\n"
+	got, err := splitSQLStatements(sql, "postgres")
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	want := []string{
+		"-- create tables\nCREATE TABLE a (note text default 'x;y');",
+		"CREATE TABLE \"b;c\" (id int);",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitSQLStatementsDollarQuoting(t *testing.T) {
+	sql := "CREATE FUNCTION f() RETURNS trigger AS $body$\nBEGIN\n  NEW.x := 'a;b';\n  RETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql;\nSELECT 1;"
+	got, err := splitSQLStatements(sql, "postgres")
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(got) != 2 || got[1] != "SELECT 1;" {
+		t.Fatalf("unexpected statements: %q", got)
+	}
+}
+
+func TestSplitSQLStatementsGoBatches(t *testing.T) {
+	sql := "CREATE TABLE [a;b] (id int);\nINSERT INTO [a;b] VALUES (1);\nGO\nCREATE PROCEDURE p AS\nBEGIN\n  SELECT 1;\nEND\ngo 2\n"
+	got, err := splitSQLStatements(sql, "sqlserver")
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 batches, got %q", got)
+	}
+	if got[1] != "CREATE PROCEDURE p AS\nBEGIN\n  SELECT 1;\nEND" {
+		t.Fatalf("unexpected batch: %q", got[1])
+	}
+}
+
+func TestSplitSQLStatementsExplicitBlock(t *testing.T) {
+	sql := "SELECT 1;\n-- +migrate StatementBegin\nCREATE PROCEDURE p() BEGIN SELECT 'it\\'s'; SELECT 2; END;\n-- +migrate StatementEnd\nSELECT 3;"
+	got, err := splitSQLStatements(sql, "mysql")
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	want := []string{
+		"SELECT 1;",
+		"CREATE PROCEDURE p() BEGIN SELECT 'it\\'s'; SELECT 2; END;",
+		"SELECT 3;",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitSQLStatementsErrors(t *testing.T) {
+	cases := []string{
+		"SELECT 'unterminated;",
+		"-- +migrate StatementBegin\nSELECT 1;",
+		"SELECT 1;\n-- +migrate StatementEnd",
+	}
+	for _, sql := range cases {
+		if _, err := splitSQLStatements(sql, "postgres"); err == nil {
+			t.Fatalf("expected error for %q", sql)
+		}
+	}
+}
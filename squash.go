@@ -0,0 +1,491 @@
+package driftflow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// squashedSuffix ends the version of a baseline written by Squash, so it sorts
+// right after the last version it replaces and before every later one.
+const squashedSuffix = "_squashed"
+
+// SquashOptions configures Squash.
+type SquashOptions struct {
+	// Dir holds the migrations; empty uses MIG_DIR, then "migrations".
+	Dir string
+	// Through is the last version replaced by the baseline.
+	Through string
+	// Engine is the dialect of the rendered SQL; empty uses DB_TYPE, like
+	// GenerateModelMigrations.
+	Engine string
+	// DryRun renders the baseline without writing or deleting any file.
+	DryRun bool
+}
+
+// SquashResult describes the baseline written by Squash.
+type SquashResult struct {
+	Version  string   `json:"version"`
+	File     string   `json:"file"`
+	Squashed []string `json:"squashed"`
+	// Tables lists the tables the baseline creates, in foreign key order.
+	Tables []string `json:"tables"`
+	Up     string   `json:"up"`
+	Down   string   `json:"down"`
+}
+
+// Squash replaces every migration up to and including opts.Through with one
+// baseline migration that creates the schema those migrations leave behind,
+// and rewrites manifest.lock.json to match. The schema is replayed from the
+// DDL DriftFlow generates (CREATE/DROP TABLE, ADD/DROP/ALTER COLUMN,
+// CREATE/DROP INDEX); a migration with any other statement, or a Go
+// migration, cannot be squashed.
+//
+// The baseline lists the versions it replaces. A database that applied them
+// records the baseline as applied on its next run instead of executing it, so
+// existing environments keep working; a database that applied only some of
+// them is refused.
+func Squash(opts SquashOptions) (SquashResult, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = os.Getenv("MIG_DIR")
+		if dir == "" {
+			dir = "migrations"
+		}
+	}
+	src := dirSource(dir)
+	if err := checkSource(src, ObserverFunc(notify)); err != nil {
+		return SquashResult{}, err
+	}
+	if opts.Through == "" {
+		return SquashResult{}, fmt.Errorf("no version to squash through given")
+	}
+	manifest, err := readManifest(src, src.path(manifestFile))
+	if err != nil {
+		return SquashResult{}, err
+	}
+	for _, e := range manifest.Migrations {
+		if v := strings.TrimSuffix(e.Name, goManifestSuffix); v != e.Name && v <= opts.Through {
+			return SquashResult{}, fmt.Errorf("go migration %s cannot be squashed", v)
+		}
+	}
+	files, err := readMigrationFiles(src)
+	if err != nil {
+		return SquashResult{}, err
+	}
+
+	version := opts.Through + squashedSuffix
+	engine := normalizeEngine(opts.Engine)
+	if engine == "" {
+		engine = normalizeEngine(os.Getenv("DB_TYPE"))
+	}
+	schema := newSquashSchema()
+	var (
+		squashed []string
+		replaced []string
+		found    bool
+	)
+	for _, f := range files {
+		v := migrationVersionFromFilename(f)
+		if v > opts.Through {
+			if v <= version {
+				return SquashResult{}, fmt.Errorf("migration %s conflicts with the baseline %s", v, version)
+			}
+			break
+		}
+		script, _, err := readMigrationScript(src, f, ChecksumRaw)
+		if err != nil {
+			return SquashResult{}, fmt.Errorf("%s: %w", f, err)
+		}
+		for _, sec := range script.Sections {
+			if len(sec.Envs) > 0 || len(sec.Dialects) > 0 {
+				return SquashResult{}, fmt.Errorf("%s: env and dialect sections cannot be squashed", f)
+			}
+		}
+		stmts, err := splitSQLStatements(script.Up, engine)
+		if err != nil {
+			return SquashResult{}, fmt.Errorf("%s: %w", f, err)
+		}
+		for _, stmt := range stmts {
+			if err := schema.apply(stmt); err != nil {
+				return SquashResult{}, fmt.Errorf("%s: %w", f, err)
+			}
+		}
+		squashed = append(squashed, script.Squashes...)
+		squashed = append(squashed, v)
+		replaced = append(replaced, f)
+		found = found || v == opts.Through
+	}
+	if !found {
+		return SquashResult{}, fmt.Errorf("target version not found: %s", opts.Through)
+	}
+	if len(schema.order) == 0 {
+		return SquashResult{}, fmt.Errorf("migrations through %s leave no tables to squash", opts.Through)
+	}
+	sort.Strings(squashed)
+
+	res := SquashResult{
+		Version:  version,
+		File:     filepath.Join(dir, version+".sql"),
+		Squashed: squashed,
+	}
+	res.Up, res.Down, res.Tables = schema.render(engine)
+	if opts.DryRun {
+		return res, nil
+	}
+
+	if _, err := os.Stat(res.File); err == nil {
+		return SquashResult{}, fmt.Errorf("migration already exists (refusing to overwrite): %s", res.File)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-- Baseline of %d squashed migrations through %s.\n", len(squashed), opts.Through)
+	for _, v := range squashed {
+		fmt.Fprintf(&sb, "%s %s\n", migrationSquashesMarker, v)
+	}
+	sb.WriteString("\n")
+	sb.WriteString(formatMigrationFile(res.Up, res.Down))
+	if err := os.WriteFile(res.File, []byte(sb.String()), 0o644); err != nil {
+		return SquashResult{}, err
+	}
+	notify(Event{Kind: EventMigrationWritten, Version: version, File: res.File})
+
+	manifestPath := filepath.Join(dir, manifestFile)
+	manifest, err = loadManifest(manifestPath)
+	if err != nil {
+		return SquashResult{}, err
+	}
+	drop := make(map[string]bool, len(replaced))
+	for _, f := range replaced {
+		drop[filepath.Base(f)] = true
+	}
+	kept := manifest.Migrations[:0]
+	for _, e := range manifest.Migrations {
+		if !drop[e.Name] {
+			kept = append(kept, e)
+		}
+	}
+	manifest.Migrations = kept
+	if err := appendMigrationToManifest(dir, manifest, version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return SquashResult{}, err
+	}
+	manifest.Version++
+	if err := saveManifest(manifestPath, manifest); err != nil {
+		return SquashResult{}, err
+	}
+	for _, f := range replaced {
+		if err := os.Remove(f); err != nil {
+			return SquashResult{}, err
+		}
+	}
+	return res, nil
+}
+
+var (
+	squashCreateTable = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)\s*\((.*)\)$`)
+	squashDropTable   = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(\S+)$`)
+	squashAddColumn   = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\S+)\s+ADD\s+COLUMN\s+(\S+)\s+(.+)$`)
+	squashDropColumn  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\S+)\s+DROP\s+COLUMN\s+(\S+)$`)
+	squashAlterColumn = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\S+)\s+ALTER\s+COLUMN\s+(\S+)\s+TYPE\s+(.+)$`)
+	squashCreateIndex = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(\S+)\s+ON\s+([^\s(]+)\s*\(([^)]*)\)(?:\s+WHERE\s+(.+))?$`)
+	squashDropIndex   = regexp.MustCompile(`(?is)^DROP\s+INDEX\s+(?:IF\s+EXISTS\s+)?(\S+?)(?:\s+ON\s+(\S+))?$`)
+	squashForeignKey  = regexp.MustCompile(`(?is)^FOREIGN\s+KEY\s*\(\s*(\S+?)\s*\)\s*REFERENCES\s+([^\s(]+)\s*\(\s*(\S+?)\s*\)$`)
+)
+
+// squashSchema is the schema replayed from generated migrations.
+type squashSchema struct {
+	tables map[string]*SnapshotTable
+	order  []string // tables in creation order
+}
+
+func newSquashSchema() *squashSchema {
+	return &squashSchema{tables: map[string]*SnapshotTable{}}
+}
+
+// apply replays one Up statement.
+func (s *squashSchema) apply(stmt string) error {
+	var lines []string
+	for _, line := range strings.Split(stmt, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	sql := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";"))
+	if sql == "" {
+		return nil
+	}
+
+	if m := squashCreateTable.FindStringSubmatch(sql); m != nil {
+		return s.createTable(unquoteIdent(m[1]), m[2])
+	}
+	if m := squashDropTable.FindStringSubmatch(sql); m != nil {
+		name := unquoteIdent(m[1])
+		if _, err := s.table(name); err != nil {
+			return err
+		}
+		delete(s.tables, name)
+		s.order = removeString(s.order, name)
+		return nil
+	}
+	if m := squashAddColumn.FindStringSubmatch(sql); m != nil {
+		t, err := s.table(unquoteIdent(m[1]))
+		if err != nil {
+			return err
+		}
+		col := unquoteIdent(m[2])
+		if _, ok := t.Columns[col]; !ok {
+			t.Order = append(t.Order, col)
+		}
+		t.Columns[col] = strings.TrimSpace(m[3])
+		return nil
+	}
+	if m := squashDropColumn.FindStringSubmatch(sql); m != nil {
+		t, err := s.table(unquoteIdent(m[1]))
+		if err != nil {
+			return err
+		}
+		col := unquoteIdent(m[2])
+		delete(t.Columns, col)
+		t.Order = removeString(t.Order, col)
+		fks := t.ForeignKeys[:0]
+		for _, fk := range t.ForeignKeys {
+			if fk.Column != col {
+				fks = append(fks, fk)
+			}
+		}
+		t.ForeignKeys = fks
+		return nil
+	}
+	if m := squashAlterColumn.FindStringSubmatch(sql); m != nil {
+		t, err := s.table(unquoteIdent(m[1]))
+		if err != nil {
+			return err
+		}
+		col := unquoteIdent(m[2])
+		if _, ok := t.Columns[col]; !ok {
+			return fmt.Errorf("alter of unknown column %s.%s", unquoteIdent(m[1]), col)
+		}
+		t.Columns[col] = strings.TrimSpace(m[3])
+		return nil
+	}
+	if m := squashCreateIndex.FindStringSubmatch(sql); m != nil {
+		t, err := s.table(unquoteIdent(m[3]))
+		if err != nil {
+			return err
+		}
+		idx := IndexDefinition{Name: unquoteIdent(m[2]), Unique: m[1] != "", Where: strings.TrimSpace(m[5])}
+		for _, c := range strings.Split(m[4], ",") {
+			idx.Columns = append(idx.Columns, unquoteIdent(strings.TrimSpace(c)))
+		}
+		t.Indexes = append(t.Indexes, idx)
+		return nil
+	}
+	if m := squashDropIndex.FindStringSubmatch(sql); m != nil {
+		name := unquoteIdent(m[1])
+		for _, t := range s.tables {
+			for i, idx := range t.Indexes {
+				if idx.Name == name {
+					t.Indexes = append(t.Indexes[:i], t.Indexes[i+1:]...)
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("drop of unknown index %s", name)
+	}
+	first, _, _ := strings.Cut(sql, "\n")
+	return fmt.Errorf("cannot squash statement %q: only generated DDL can be replayed", first)
+}
+
+// createTable adds a table from the body of its CREATE TABLE statement.
+func (s *squashSchema) createTable(name, body string) error {
+	if _, ok := s.tables[name]; ok {
+		return fmt.Errorf("table %s created twice", name)
+	}
+	t := &SnapshotTable{Columns: map[string]string{}}
+	for _, def := range splitTopLevel(body) {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		if m := squashForeignKey.FindStringSubmatch(def); m != nil {
+			t.ForeignKeys = append(t.ForeignKeys, foreignKeyInfo{
+				Column:    unquoteIdent(m[1]),
+				RefTable:  unquoteIdent(m[2]),
+				RefColumn: unquoteIdent(m[3]),
+			})
+			continue
+		}
+		col, typ, ok := strings.Cut(def, " ")
+		upper := strings.ToUpper(col)
+		if !ok || upper == "CONSTRAINT" || upper == "PRIMARY" || upper == "UNIQUE" || upper == "CHECK" || upper == "FOREIGN" {
+			return fmt.Errorf("cannot squash table %s: unsupported definition %q", name, def)
+		}
+		col = unquoteIdent(col)
+		t.Columns[col] = strings.TrimSpace(typ)
+		t.Order = append(t.Order, col)
+	}
+	s.tables[name] = t
+	s.order = append(s.order, name)
+	return nil
+}
+
+func (s *squashSchema) table(name string) (*SnapshotTable, error) {
+	t, ok := s.tables[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %s", name)
+	}
+	return t, nil
+}
+
+// render returns the baseline sections and its tables in foreign key order.
+func (s *squashSchema) render(engine string) (up, down string, tables []string) {
+	fkMap := make(map[string][]foreignKeyInfo, len(s.tables))
+	for name, t := range s.tables {
+		fkMap[name] = t.ForeignKeys
+	}
+	tables = orderTablesByFKDependencies(append([]string{}, s.order...), fkMap)
+	ups := make([]string, 0, len(tables))
+	downs := make([]string, 0, len(tables))
+	for _, name := range tables {
+		t := s.tables[name]
+		ups = append(ups, appendIndexSQL(createTableSQL(name, t.Columns, t.Order, t.ForeignKeys, engine), name, t.Indexes, engine))
+		downs = append([]string{fmt.Sprintf("DROP TABLE %s;", quoteIdent(engine, name))}, downs...)
+	}
+	return strings.Join(ups, "\n\n"), strings.Join(downs, "\n"), tables
+}
+
+// splitTopLevel splits s at commas outside parentheses and quotes.
+func splitTopLevel(s string) []string {
+	var (
+		parts []string
+		depth int
+		quote rune
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquoteIdent(s string) string {
+	return strings.Trim(s, "\"`[]")
+}
+
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// squashAdoption records a squashed baseline taken as applied.
+type squashAdoption struct {
+	row      SchemaMigration
+	replaced []string
+}
+
+// adoptSquashed takes every squashed baseline as applied whose replaced
+// versions are applied: in memory their rows become one baseline row, and
+// s.adopted keeps the change for saveSquashAdoptions. The newest replaced
+// version decides; a database that applied older ones only is refused, as the
+// baseline can neither run nor be skipped there.
+func (s *migrationState) adoptSquashed() error {
+	for _, v := range s.versions {
+		file, ok := s.files[v]
+		if _, applied := s.applied[v]; applied || !ok || !strings.HasSuffix(v, squashedSuffix) {
+			continue
+		}
+		script, checksum, err := readMigrationScript(s.src, file, s.algorithm)
+		if err != nil {
+			return err
+		}
+		replaced := append([]string{}, script.Squashes...)
+		sort.Strings(replaced)
+		var applied []SchemaMigration
+		for _, r := range replaced {
+			if m, ok := s.applied[r]; ok {
+				applied = append(applied, m)
+			}
+		}
+		if len(applied) == 0 {
+			continue
+		}
+		// a skipped replaced migration would lose its DDL with its row
+		if len(applied) != len(replaced) {
+			return fmt.Errorf("%s replaces %d migrations but only %d are applied; apply the rest with the migrations from before the squash first",
+				v, len(replaced), len(applied))
+		}
+		row := SchemaMigration{
+			Version:         v,
+			Checksum:        checksum,
+			ChecksumVersion: s.algorithm,
+			AppliedAt:       time.Now().UTC(),
+			Status:          HistoryStatusSquashed,
+		}
+		for _, m := range applied {
+			row.Batch = max(row.Batch, m.Batch)
+		}
+
+		isReplaced := make(map[string]bool, len(replaced))
+		for _, r := range replaced {
+			isReplaced[r] = true
+			delete(s.applied, r)
+		}
+		var order []string
+		for _, a := range s.appliedOrder {
+			if !isReplaced[a] {
+				order = append(order, a)
+			}
+			if a == replaced[len(replaced)-1] {
+				order = append(order, v)
+			}
+		}
+		s.appliedOrder = order
+		s.applied[v] = row
+		s.adopted = append(s.adopted, squashAdoption{row: row, replaced: replaced})
+	}
+	return nil
+}
+
+// saveSquashAdoptions replaces the history rows of the versions squashed into
+// each adopted baseline with the baseline's row.
+func (s *migrationState) saveSquashAdoptions(db *gorm.DB) error {
+	history := s.tables.historyTable()
+	for _, a := range s.adopted {
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Table(history).Where("version IN ?", a.replaced).Delete(&SchemaMigration{}).Error; err != nil {
+				return err
+			}
+			row := a.row
+			return tx.Table(history).Create(&row).Error
+		}); err != nil {
+			return fmt.Errorf("adopt squashed baseline %s: %w", a.row.Version, err)
+		}
+		s.audit(db, a.row.Version, "squash_adopt")
+	}
+	s.adopted = nil
+	return nil
+}
@@ -0,0 +1,194 @@
+package driftflow
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSquashFixtures writes generated-style migrations and their manifest.
+func writeSquashFixtures(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	posts := createTableSQL("posts", tableInfo{"id": "bigint", "user_id": "bigint"}, []string{"id", "user_id"},
+		[]foreignKeyInfo{{Column: "user_id", RefTable: "users", RefColumn: "id"}}, "postgres")
+	users := createTableSQL("users", tableInfo{"id": "bigint", "name": "text"}, []string{"id", "name"}, nil, "postgres")
+	writeMigration(t, dir, "001_init", users+"\n"+posts+"\nCREATE TABLE tmp(id int);", "DROP TABLE posts;\nDROP TABLE users;")
+	writeMigration(t, dir, "002_users_email",
+		`ALTER TABLE "users" ADD COLUMN "email" varchar(255);`+"\n"+
+			`ALTER TABLE "users" ALTER COLUMN "name" TYPE varchar(100);`+"\n"+
+			createIndexSQL("users", IndexDefinition{Name: "idx_users_email", Columns: []string{"email"}, Unique: true}, "postgres")+"\n"+
+			"DROP TABLE tmp;",
+		`ALTER TABLE "users" DROP COLUMN "email";`)
+	writeMigration(t, dir, "003_tags", "CREATE TABLE tags(id int);", "DROP TABLE tags;")
+
+	manifest := &ManifestLock{Migrations: []ManifestEntry{}}
+	for _, name := range []string{"001_init", "002_users_email", "003_tags"} {
+		if err := appendMigrationToManifest(dir, manifest, name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			t.Fatalf("appendMigrationToManifest: %v", err)
+		}
+	}
+	if err := saveManifest(filepath.Join(dir, manifestFile), manifest); err != nil {
+		t.Fatalf("saveManifest: %v", err)
+	}
+	return dir
+}
+
+func TestSquashSchemaReplay(t *testing.T) {
+	s := newSquashSchema()
+	stmts := []string{
+		`CREATE TABLE "users" (` + "\n" + `  "id" bigint,` + "\n" + `  "price" numeric(10,2)` + "\n)",
+		`CREATE TABLE "posts" ("id" bigint, "user_id" bigint, FOREIGN KEY ("user_id") REFERENCES "users"("id"))`,
+		`ALTER TABLE "users" ADD COLUMN "email" text`,
+		`ALTER TABLE "users" DROP COLUMN "price"`,
+		`CREATE INDEX "idx_posts_user" ON "posts" ("user_id")`,
+	}
+	for _, stmt := range stmts {
+		if err := s.apply(stmt); err != nil {
+			t.Fatalf("apply %q: %v", stmt, err)
+		}
+	}
+	if got := s.tables["users"].Order; strings.Join(got, ",") != "id,email" {
+		t.Fatalf("unexpected users columns: %v", got)
+	}
+	if fks := s.tables["posts"].ForeignKeys; len(fks) != 1 || fks[0].RefTable != "users" {
+		t.Fatalf("unexpected foreign keys: %+v", fks)
+	}
+	if idx := s.tables["posts"].Indexes; len(idx) != 1 || idx[0].Columns[0] != "user_id" {
+		t.Fatalf("unexpected indexes: %+v", idx)
+	}
+	if err := s.apply("UPDATE users SET email = ''"); err == nil || !strings.Contains(err.Error(), "cannot squash statement") {
+		t.Fatalf("expected data statements to be refused, got %v", err)
+	}
+}
+
+func TestSquash(t *testing.T) {
+	dir := writeSquashFixtures(t)
+
+	res, err := Squash(SquashOptions{Dir: dir, Through: "002_users_email", Engine: "postgres"})
+	if err != nil {
+		t.Fatalf("Squash: %v", err)
+	}
+	if res.Version != "002_users_email_squashed" || strings.Join(res.Squashed, ",") != "001_init,002_users_email" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if strings.Join(res.Tables, ",") != "users,posts" {
+		t.Fatalf("expected tables in foreign key order, got %v", res.Tables)
+	}
+	for _, want := range []string{`"email" varchar(255)`, `"name" varchar(100)`, "idx_users_email"} {
+		if !strings.Contains(res.Up, want) {
+			t.Fatalf("expected %q in baseline:\n%s", want, res.Up)
+		}
+	}
+	if strings.Contains(res.Up, "tmp") {
+		t.Fatalf("dropped table in baseline:\n%s", res.Up)
+	}
+	if !strings.HasPrefix(res.Down, `DROP TABLE "posts";`) {
+		t.Fatalf("expected dependants dropped first:\n%s", res.Down)
+	}
+
+	for _, name := range []string{"001_init.sql", "002_users_email.sql"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s removed, got %v", name, err)
+		}
+	}
+	b, err := os.ReadFile(res.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, err := parseMigrationScript(string(b))
+	if err != nil || strings.Join(script.Squashes, ",") != "001_init,002_users_email" {
+		t.Fatalf("unexpected baseline file: %+v, %v", script, err)
+	}
+	if err := checkSource(dirSource(dir), ObserverFunc(notify)); err != nil {
+		t.Fatalf("expected a consistent manifest after squash, got %v", err)
+	}
+
+	// squashing again through the baseline keeps its history
+	res, err = Squash(SquashOptions{Dir: dir, Through: "003_tags", Engine: "postgres", DryRun: true})
+	if err != nil {
+		t.Fatalf("Squash: %v", err)
+	}
+	if strings.Join(res.Squashed, ",") != "001_init,002_users_email,002_users_email_squashed,003_tags" {
+		t.Fatalf("unexpected squashed versions: %v", res.Squashed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "003_tags.sql")); err != nil {
+		t.Fatalf("dry run must not touch files: %v", err)
+	}
+}
+
+func TestSquashRejectsUnknownTarget(t *testing.T) {
+	dir := writeSquashFixtures(t)
+	if _, err := Squash(SquashOptions{Dir: dir, Through: "002_missing", Engine: "postgres"}); err == nil || !strings.Contains(err.Error(), "target version not found") {
+		t.Fatalf("expected target error, got %v", err)
+	}
+}
+
+func TestAdoptSquashed(t *testing.T) {
+	dir := writeSquashFixtures(t)
+	if _, err := Squash(SquashOptions{Dir: dir, Through: "002_users_email", Engine: "postgres"}); err != nil {
+		t.Fatalf("Squash: %v", err)
+	}
+	baseline := "002_users_email_squashed"
+
+	// an existing database recognises the baseline as applied
+	s := newTestState(t, dir)
+	s.applied["001_init"] = SchemaMigration{Version: "001_init", Batch: 1}
+	s.applied["002_users_email"] = SchemaMigration{Version: "002_users_email", Batch: 2}
+	s.appliedOrder = []string{"001_init", "002_users_email"}
+	if err := s.adoptSquashed(); err != nil {
+		t.Fatalf("adoptSquashed: %v", err)
+	}
+	m, ok := s.applied[baseline]
+	if !ok || m.Status != HistoryStatusSquashed || m.Batch != 2 || len(s.applied) != 1 {
+		t.Fatalf("expected the baseline to replace the squashed rows, got %+v", s.applied)
+	}
+	if len(s.adopted) != 1 || strings.Join(s.adopted[0].replaced, ",") != "001_init,002_users_email" {
+		t.Fatalf("unexpected adoptions: %+v", s.adopted)
+	}
+	plan, err := s.planUp()
+	if err != nil {
+		t.Fatalf("planUp: %v", err)
+	}
+	if len(plan) != 1 || plan[0].Version != "003_tags" {
+		t.Fatalf("expected only 003_tags pending, got %+v", plan)
+	}
+
+	// a new database runs the baseline
+	s = newTestState(t, dir)
+	if err := s.adoptSquashed(); err != nil || len(s.adopted) != 0 {
+		t.Fatalf("expected nothing adopted, got %+v, %v", s.adopted, err)
+	}
+	if plan, err := s.planUp(); err != nil || plan[0].Version != baseline {
+		t.Fatalf("expected the baseline to run first, got %+v, %v", plan, err)
+	}
+
+	// a database in between is refused
+	s = newTestState(t, dir)
+	s.applied["001_init"] = SchemaMigration{Version: "001_init", Batch: 1}
+	s.appliedOrder = []string{"001_init"}
+	if err := s.adoptSquashed(); err == nil || !strings.Contains(err.Error(), "only 1 are applied") {
+		t.Fatalf("expected partial history error, got %v", err)
+	}
+}
+
+func TestAdoptSquashedRefusesGap(t *testing.T) {
+	dir := writeSquashFixtures(t)
+	if _, err := Squash(SquashOptions{Dir: dir, Through: "003_tags", Engine: "postgres"}); err != nil {
+		t.Fatalf("Squash: %v", err)
+	}
+
+	// 003_tags was applied out of order and 002_users_email never was
+	s := newTestState(t, dir)
+	s.applied["001_init"] = SchemaMigration{Version: "001_init", Batch: 1}
+	s.applied["003_tags"] = SchemaMigration{Version: "003_tags", Batch: 2}
+	s.appliedOrder = []string{"001_init", "003_tags"}
+	if err := s.adoptSquashed(); err == nil || !strings.Contains(err.Error(), "only 2 are applied") {
+		t.Fatalf("expected partial history error, got %v", err)
+	}
+	if _, ok := s.applied["003_tags_squashed"]; ok || len(s.applied) != 2 {
+		t.Fatalf("expected the history to be left alone, got %+v", s.applied)
+	}
+}
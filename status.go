@@ -0,0 +1,173 @@
+package driftflow
+
+import (
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MigrationState classifies a migration in a status report.
+type MigrationState string
+
+const (
+	StateApplied    MigrationState = "applied"
+	StatePending    MigrationState = "pending"
+	StateMissing    MigrationState = "missing_on_disk"
+	StateModified   MigrationState = "checksum_modified"
+	StateOutOfOrder MigrationState = "out_of_order"
+)
+
+// MigrationStatus is one row of the status report. Manifest is empty when the
+// file matches manifest.lock.json, otherwise it holds the manifest issue type.
+// AppliedOutOfOrder marks rows recorded after a newer version, which only
+// happens when running with AllowOutOfOrder.
+type MigrationStatus struct {
+	Version           string            `json:"version"`
+	File              string            `json:"file,omitempty"`
+	State             MigrationState    `json:"state"`
+	Batch             int               `json:"batch,omitempty"`
+	AppliedAt         *time.Time        `json:"applied_at,omitempty"`
+	AppliedOutOfOrder bool              `json:"applied_out_of_order,omitempty"`
+	Manifest          ManifestIssueType `json:"manifest,omitempty"`
+	// Repeatable marks repeatable migrations; they are pending when their
+	// file changed since they were last applied.
+	Repeatable bool `json:"repeatable,omitempty"`
+}
+
+// Status joins the files in dir, manifest.lock.json and migrations_history and
+// reports the state of every known version. It never modifies the database.
+func Status(db *gorm.DB, dir string) ([]MigrationStatus, error) {
+	return StatusWithOptions(db, dir, MigrateOptions{})
+}
+
+// StatusWithOptions is Status with explicit runner options. Tables,
+// ChecksumAlgorithm and GoMigrations shape the report; the options that only
+// affect running migrations (lock, timeouts, hooks, environment) are ignored.
+func StatusWithOptions(db *gorm.DB, dir string, opts MigrateOptions) ([]MigrationStatus, error) {
+	return loadStatus(db, dirSource(dir).withGoMigrations(opts.GoMigrations), opts)
+}
+
+func loadStatus(db *gorm.DB, src migrationSource, opts MigrateOptions) ([]MigrationStatus, error) {
+	if err := src.validate(); err != nil {
+		return nil, err
+	}
+	manifest, err := readManifest(src, src.path(manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := migrateManifest(src, manifest); err != nil {
+		return nil, err
+	}
+	issues, err := validateManifest(src, manifest)
+	if err != nil {
+		return nil, err
+	}
+	state, err := loadMigrationState(db, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	return state.status(issues)
+}
+
+func (s *migrationState) status(issues []ManifestIssue) ([]MigrationStatus, error) {
+	manifestIssues := make(map[string]ManifestIssueType, len(issues))
+	for _, is := range issues {
+		manifestIssues[manifestEntryVersion(is.Migration)] = is.Type
+	}
+
+	lastApplied := s.lastAppliedIndex()
+
+	appliedOutOfOrder := map[string]bool{}
+	newest := ""
+	for _, v := range s.appliedOrder {
+		if newest != "" && s.before(v, newest) {
+			appliedOutOfOrder[v] = true
+		} else {
+			newest = v
+		}
+	}
+
+	var rows []MigrationStatus
+	seen := make(map[string]bool, len(s.versions))
+	for i, v := range s.versions {
+		seen[v] = true
+		row := MigrationStatus{Version: v, File: s.files[v], Manifest: manifestIssues[v]}
+		m, ok := s.applied[v]
+		if !ok {
+			row.State = StatePending
+			if i < lastApplied && !s.dependenciesApplied(v) {
+				row.State = StateOutOfOrder
+			}
+			rows = append(rows, row)
+			continue
+		}
+		checksum, err := s.checksum(v)
+		if err != nil {
+			return nil, err
+		}
+		row.State = StateApplied
+		if m.Checksum != checksum {
+			row.State = StateModified
+		}
+		row.Batch = m.Batch
+		appliedAt := m.AppliedAt
+		row.AppliedAt = &appliedAt
+		row.AppliedOutOfOrder = appliedOutOfOrder[v]
+		rows = append(rows, row)
+	}
+
+	for _, v := range s.repeatables {
+		seen[v] = true
+		row := MigrationStatus{Version: v, File: s.repeatableFiles[v], State: StatePending, Manifest: manifestIssues[v], Repeatable: true}
+		_, checksum, err := readRepeatable(s.src, s.repeatableFiles[v], s.algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := s.repeatableApplied[v]; ok {
+			if m.Checksum == checksum {
+				row.State = StateApplied
+			}
+			row.Batch = m.Batch
+			appliedAt := m.AppliedAt
+			row.AppliedAt = &appliedAt
+		}
+		rows = append(rows, row)
+	}
+	for v, m := range s.repeatableApplied {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		appliedAt := m.AppliedAt
+		rows = append(rows, MigrationStatus{Version: v, State: StateMissing, Batch: m.Batch, AppliedAt: &appliedAt, Manifest: manifestIssues[v], Repeatable: true})
+	}
+
+	for v, m := range s.applied {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		appliedAt := m.AppliedAt
+		rows = append(rows, MigrationStatus{
+			Version:   v,
+			State:     StateMissing,
+			Batch:     m.Batch,
+			AppliedAt: &appliedAt,
+			Manifest:  manifestIssues[v],
+		})
+	}
+
+	// manifest entries with neither a file nor a history row
+	for v, t := range manifestIssues {
+		if seen[v] {
+			continue
+		}
+		rows = append(rows, MigrationStatus{Version: v, State: StateMissing, Manifest: t})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return s.before(rows[i].Version, rows[j].Version)
+	})
+	return rows, nil
+}
@@ -0,0 +1,63 @@
+package driftflow
+
+import "testing"
+
+func TestStatusStates(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users", "003_tags")
+	s.applied["000_legacy"] = SchemaMigration{Version: "000_legacy", Batch: 1}
+	m := s.applied["003_tags"]
+	m.Checksum = "stale"
+	s.applied["003_tags"] = m
+
+	rows, err := s.status([]ManifestIssue{{Type: IssueUntracked, Migration: "003_tags.sql"}})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	want := map[string]MigrationState{
+		"000_legacy": StateMissing,
+		"001_users":  StateApplied,
+		"002_posts":  StateOutOfOrder,
+		"003_tags":   StateModified,
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d rows, got %+v", len(want), rows)
+	}
+	for _, r := range rows {
+		if r.State != want[r.Version] {
+			t.Fatalf("%s: expected %s, got %s", r.Version, want[r.Version], r.State)
+		}
+	}
+	if rows[0].Version != "000_legacy" {
+		t.Fatalf("expected rows sorted by version, got %+v", rows)
+	}
+	if rows[3].Manifest != IssueUntracked || rows[1].Manifest != "" {
+		t.Fatalf("unexpected manifest columns: %+v", rows)
+	}
+	if rows[1].Batch != 1 || rows[1].AppliedAt == nil {
+		t.Fatalf("expected batch and applied_at for applied row: %+v", rows[1])
+	}
+}
+
+func TestStatusPendingAfterLastApplied(t *testing.T) {
+	dir := writePlanFixtures(t)
+	rows, err := newTestState(t, dir, "001_users").status(nil)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if rows[1].State != StatePending || rows[2].State != StatePending {
+		t.Fatalf("expected pending rows, got %+v", rows)
+	}
+}
+
+func TestStatusFlagsAppliedOutOfOrder(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users", "003_tags", "002_posts")
+	rows, err := s.status(nil)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !rows[1].AppliedOutOfOrder || rows[2].AppliedOutOfOrder || rows[1].State != StateApplied {
+		t.Fatalf("expected only 002_posts flagged as applied out of order: %+v", rows)
+	}
+}
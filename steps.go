@@ -0,0 +1,125 @@
+package driftflow
+
+import (
+	"gorm.io/gorm"
+)
+
+// UpSteps applies the next n pending migrations. If n is less than 1 or at
+// least the number of pending migrations, it behaves like Up, including the
+// repeatable migrations.
+func UpSteps(db *gorm.DB, dir string, n int) error {
+	return UpStepsWithOptions(db, dir, n, MigrateOptions{})
+}
+
+// UpStepsWithOptions is UpSteps with explicit runner options.
+func UpStepsWithOptions(db *gorm.DB, dir string, n int, opts MigrateOptions) error {
+	return runPlanned(db, dirSource(dir).withGoMigrations(opts.GoMigrations), opts, false, func(s *migrationState) ([]PlannedMigration, error) {
+		return s.planUpSteps(n)
+	})
+}
+
+// PlanUpSteps returns the steps UpStepsWithOptions(n) would execute without
+// running them.
+func PlanUpSteps(db *gorm.DB, dir string, n int, opts MigrateOptions) ([]PlannedMigration, error) {
+	return planned(db, dirSource(dir).withGoMigrations(opts.GoMigrations), opts, func(s *migrationState) ([]PlannedMigration, error) {
+		return s.planUpSteps(n)
+	})
+}
+
+// Redo rolls back the last n migrations and applies them again in one run,
+// the loop of writing a migration. If n is less than 1 or greater than the
+// number of applied migrations, all of them are redone.
+func Redo(db *gorm.DB, dir string, n int) error {
+	return RedoWithOptions(db, dir, n, MigrateOptions{})
+}
+
+// RedoWithOptions is Redo with explicit runner options.
+func RedoWithOptions(db *gorm.DB, dir string, n int, opts MigrateOptions) error {
+	return runPlanned(db, dirSource(dir).withGoMigrations(opts.GoMigrations), opts, true, func(s *migrationState) ([]PlannedMigration, error) {
+		return s.planRedo(n)
+	})
+}
+
+// PlanRedo returns the steps RedoWithOptions(n) would execute without running
+// them.
+func PlanRedo(db *gorm.DB, dir string, n int, opts MigrateOptions) ([]PlannedMigration, error) {
+	return planned(db, dirSource(dir).withGoMigrations(opts.GoMigrations), opts, func(s *migrationState) ([]PlannedMigration, error) {
+		return s.planRedo(n)
+	})
+}
+
+// runPlanned checks src and executes the steps returned by plan under the
+// migration lock. fieldHistory also creates the field history table, which
+// rollbacks write to.
+func runPlanned(db *gorm.DB, src migrationSource, opts MigrateOptions, fieldHistory bool, plan func(s *migrationState) ([]PlannedMigration, error)) error {
+	if err := checkSource(src, opts.observer()); err != nil {
+		return err
+	}
+	return withMigrationLock(db, opts, func(db *gorm.DB) error {
+		if err := ensureMigrationsTable(db, opts.tables()); err != nil {
+			return err
+		}
+		ensureAuditTables(db, opts, fieldHistory)
+		state, err := loadMigrationState(db, src, opts)
+		if err != nil {
+			return err
+		}
+		steps, err := plan(state)
+		if err != nil {
+			return err
+		}
+		return state.execute(db, steps)
+	})
+}
+
+// planned checks src and returns the steps of plan without executing them.
+func planned(db *gorm.DB, src migrationSource, opts MigrateOptions, plan func(s *migrationState) ([]PlannedMigration, error)) ([]PlannedMigration, error) {
+	if err := checkSource(src, opts.observer()); err != nil {
+		return nil, err
+	}
+	state, err := loadMigrationState(db, src, opts)
+	if err != nil {
+		return nil, err
+	}
+	return plan(state)
+}
+
+// planUpSteps returns the first n steps of planUp. The repeatable migrations
+// come last, so they only follow a run that applies every pending migration.
+func (s *migrationState) planUpSteps(n int) ([]PlannedMigration, error) {
+	plan, err := s.planUp()
+	if err != nil {
+		return nil, err
+	}
+	pending := 0
+	for _, step := range plan {
+		if !step.Repeatable {
+			pending++
+		}
+	}
+	if n < 1 || n >= pending {
+		return plan, nil
+	}
+	return plan[:n], nil
+}
+
+// planRedo rolls back the last n migrations, newest first, and applies them
+// again, oldest first. Every applied migration is verified like in planUp.
+func (s *migrationState) planRedo(n int) ([]PlannedMigration, error) {
+	if err := s.verifyApplied(); err != nil {
+		return nil, err
+	}
+	down, err := s.planDownSteps(n)
+	if err != nil {
+		return nil, err
+	}
+	plan := down
+	for i := len(down) - 1; i >= 0; i-- {
+		step, err := s.upStep(down[i].Version)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, step)
+	}
+	return plan, nil
+}
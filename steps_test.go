@@ -0,0 +1,73 @@
+package driftflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanUpSteps(t *testing.T) {
+	dir := writePlanFixtures(t)
+	writeRepeatable(t, dir, "R__views", "CREATE OR REPLACE VIEW v AS SELECT 1;")
+	s := newTestState(t, dir)
+
+	plan, err := s.planUpSteps(2)
+	if err != nil {
+		t.Fatalf("planUpSteps: %v", err)
+	}
+	if len(plan) != 2 || plan[0].Version != "001_users" || plan[1].Version != "002_posts" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+
+	plan, err = s.planUpSteps(3)
+	if err != nil {
+		t.Fatalf("planUpSteps: %v", err)
+	}
+	if len(plan) != 4 || !plan[3].Repeatable {
+		t.Fatalf("expected the repeatables after the last pending migration, got %+v", plan)
+	}
+}
+
+func TestPlanRedo(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users", "002_posts", "003_tags")
+
+	plan, err := s.planRedo(2)
+	if err != nil {
+		t.Fatalf("planRedo: %v", err)
+	}
+	want := []struct {
+		version   string
+		direction MigrationDirection
+	}{
+		{"003_tags", DirectionDown},
+		{"002_posts", DirectionDown},
+		{"002_posts", DirectionUp},
+		{"003_tags", DirectionUp},
+	}
+	if len(plan) != len(want) {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	for i, w := range want {
+		if plan[i].Version != w.version || plan[i].Direction != w.direction {
+			t.Fatalf("step %d: expected %s %s, got %s %s", i, w.direction, w.version, plan[i].Direction, plan[i].Version)
+		}
+	}
+	if plan[2].Batch != s.nextBatch {
+		t.Fatalf("expected re-applied steps in a new batch, got %d", plan[2].Batch)
+	}
+}
+
+func TestPlanRedoVerifiesChecksums(t *testing.T) {
+	dir := writePlanFixtures(t)
+	s := newTestState(t, dir, "001_users", "002_posts")
+	m := s.applied["001_users"]
+	m.Checksum = "other"
+	s.applied["001_users"] = m
+
+	if _, err := s.planRedo(1); err == nil || !strings.Contains(err.Error(), "modified after applied: 001_users") {
+		t.Fatalf("expected modified error, got %v", err)
+	}
+	if _, err := s.planMigrateTo("002_posts"); err == nil || !strings.Contains(err.Error(), "modified after applied") {
+		t.Fatalf("expected goto to verify checksums, got %v", err)
+	}
+}
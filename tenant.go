@@ -0,0 +1,291 @@
+package driftflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tenant is one target of a multi-tenant run. Schema selects a Postgres
+// schema of the runner's database through search_path; DSN points at a
+// database of its own. With both set, the schema is selected in the DSN's
+// database. Every tenant keeps its own migrations_history.
+type Tenant struct {
+	Name   string `gorm:"column:name" json:"name"`
+	Schema string `gorm:"column:schema" json:"schema,omitempty"`
+	DSN    string `gorm:"column:dsn" json:"-"`
+}
+
+// TenantSource lists the tenants of a run. db is the database given to the
+// runner.
+type TenantSource interface {
+	Tenants(db *gorm.DB) ([]Tenant, error)
+}
+
+// TenantList is a static TenantSource.
+type TenantList []Tenant
+
+func (l TenantList) Tenants(*gorm.DB) ([]Tenant, error) { return l, nil }
+
+// TenantSchemas returns a TenantList of Postgres schemas, each tenant named
+// after its schema.
+func TenantSchemas(schemas ...string) TenantList {
+	l := make(TenantList, 0, len(schemas))
+	for _, s := range schemas {
+		l = append(l, Tenant{Name: s, Schema: s})
+	}
+	return l
+}
+
+// TenantQuery is a TenantSource that reads the tenants from the runner's
+// database. Columns are matched by name (name, schema, dsn); a tenant without
+// a name is named after its schema.
+type TenantQuery string
+
+func (q TenantQuery) Tenants(db *gorm.DB) ([]Tenant, error) {
+	var tenants []Tenant
+	if err := db.Raw(string(q)).Scan(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("list tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// TenantFunc adapts a function to the TenantSource interface.
+type TenantFunc func(db *gorm.DB) ([]Tenant, error)
+
+func (f TenantFunc) Tenants(db *gorm.DB) ([]Tenant, error) { return f(db) }
+
+// TenantFailurePolicy decides whether a run goes on after a tenant fails.
+type TenantFailurePolicy int
+
+const (
+	// TenantStopOnError starts no further tenant after a failure; tenants
+	// already running finish and the rest are reported as skipped.
+	TenantStopOnError TenantFailurePolicy = iota
+	// TenantContinueOnError runs every tenant regardless of failures.
+	TenantContinueOnError
+)
+
+// TenantOptions configures UpTenants and StatusTenants.
+type TenantOptions struct {
+	// MigrateOptions apply to every tenant. The migration lock is taken per
+	// tenant, so tenants of one database do not block each other. With
+	// Parallelism above 1, Hooks and Observer are called concurrently.
+	MigrateOptions
+	Source TenantSource
+	// Parallelism bounds how many tenants run at once; below 1 means 1.
+	Parallelism int
+	OnFailure   TenantFailurePolicy
+	// Open connects to a tenant DSN. Nil uses ConnectToDB with the driver of
+	// the runner's database.
+	Open func(dsn string) (*gorm.DB, error)
+}
+
+// TenantRunStatus is the outcome of one tenant.
+type TenantRunStatus string
+
+const (
+	TenantOK      TenantRunStatus = "ok"
+	TenantFailed  TenantRunStatus = "failed"
+	TenantSkipped TenantRunStatus = "skipped"
+)
+
+// TenantResult is the outcome of one tenant in a TenantReport.
+type TenantResult struct {
+	Tenant Tenant          `json:"tenant"`
+	Status TenantRunStatus `json:"status"`
+	// Applied lists the versions UpTenants applied, in order.
+	Applied []string `json:"applied,omitempty"`
+	// Migrations is the Status report read by StatusTenants.
+	Migrations []MigrationStatus `json:"migrations,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+	Err        error             `json:"-"`
+	// Error is Err as text, for JSON reports.
+	Error string `json:"error,omitempty"`
+}
+
+// TenantReport holds one result per tenant, in the order of the source.
+type TenantReport struct {
+	Results []TenantResult `json:"results"`
+}
+
+// Err joins the errors of the failed tenants, or returns nil.
+func (r TenantReport) Err() error {
+	var errs []error
+	for _, res := range r.Results {
+		if res.Err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", res.Tenant.Name, res.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// UpTenants applies the migrations in dir to every tenant of opts.Source.
+// The error is the listing failure or TenantReport.Err; the report has the
+// outcome of each tenant either way.
+func UpTenants(db *gorm.DB, dir string, opts TenantOptions) (TenantReport, error) {
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	if err := checkSource(src, opts.observer()); err != nil {
+		return TenantReport{}, err
+	}
+	return runTenants(db, opts, func(tdb *gorm.DB, mo MigrateOptions, _ *TenantResult) error {
+		return runUp(tdb, src, mo)
+	})
+}
+
+// StatusTenants reads the Status report of every tenant of opts.Source.
+func StatusTenants(db *gorm.DB, dir string, opts TenantOptions) (TenantReport, error) {
+	src := dirSource(dir).withGoMigrations(opts.GoMigrations)
+	return runTenants(db, opts, func(tdb *gorm.DB, mo MigrateOptions, res *TenantResult) error {
+		rows, err := loadStatus(tdb, src, mo)
+		res.Migrations = rows
+		return err
+	})
+}
+
+// runTenants lists the tenants and calls fn for each of them with at most
+// opts.Parallelism calls in flight. Tenants not started when db's context is
+// cancelled, or after a failure under TenantStopOnError, are skipped.
+func runTenants(db *gorm.DB, opts TenantOptions, fn func(tdb *gorm.DB, mo MigrateOptions, res *TenantResult) error) (TenantReport, error) {
+	if opts.Source == nil {
+		return TenantReport{}, errors.New("no tenant source given")
+	}
+	tenants, err := opts.Source.Tenants(db)
+	if err != nil {
+		return TenantReport{}, err
+	}
+	if err := normalizeTenants(tenants); err != nil {
+		return TenantReport{}, err
+	}
+	if schema := opts.tables().Schema; schema != "" {
+		for _, t := range tenants {
+			if t.Schema != "" {
+				return TenantReport{}, fmt.Errorf("tenant %s: schema tenants would share the metadata tables in schema %s", t.Name, schema)
+			}
+		}
+	}
+	open := opts.Open
+	if open == nil {
+		driver := db.Dialector.Name()
+		open = func(dsn string) (*gorm.DB, error) { return ConnectToDB(dsn, driver) }
+	}
+
+	report := TenantReport{Results: make([]TenantResult, len(tenants))}
+	sem := make(chan struct{}, max(opts.Parallelism, 1))
+	var (
+		wg      sync.WaitGroup
+		stopped atomic.Bool
+	)
+	for i, t := range tenants {
+		res := &report.Results[i]
+		res.Tenant = t
+		sem <- struct{}{}
+		if stopped.Load() || dbContext(db).Err() != nil {
+			<-sem
+			res.Status = TenantSkipped
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			started := time.Now()
+			err := withTenant(db, t, open, func(tdb *gorm.DB) error {
+				return fn(tdb, opts.forTenant(t, res), res)
+			})
+			res.DurationMs = time.Since(started).Milliseconds()
+			if err != nil {
+				res.Status, res.Err, res.Error = TenantFailed, err, err.Error()
+				if opts.OnFailure == TenantStopOnError {
+					stopped.Store(true)
+				}
+				return
+			}
+			res.Status = TenantOK
+		}()
+	}
+	wg.Wait()
+	return report, report.Err()
+}
+
+// normalizeTenants names unnamed tenants after their schema and rejects
+// tenants without a target or with a duplicate name.
+func normalizeTenants(tenants []Tenant) error {
+	seen := make(map[string]bool, len(tenants))
+	for i := range tenants {
+		t := &tenants[i]
+		if t.Name == "" {
+			t.Name = t.Schema
+		}
+		if t.Name == "" {
+			return fmt.Errorf("tenant %d has no name or schema", i+1)
+		}
+		if t.Schema == "" && t.DSN == "" {
+			return fmt.Errorf("tenant %s has no schema or DSN", t.Name)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("duplicate tenant: %s", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return nil
+}
+
+// forTenant returns the MigrateOptions of t's run: its own lock, and an
+// observer that tags events with the tenant and records applied versions in
+// res.
+func (o TenantOptions) forTenant(t Tenant, res *TenantResult) MigrateOptions {
+	mo := o.MigrateOptions
+	mo.tenant = t.Name
+	obs := mo.observer()
+	mo.Observer = ObserverFunc(func(e Event) {
+		e.Tenant = t.Name
+		if e.Kind == EventMigrationApplied {
+			res.Applied = append(res.Applied, e.Version)
+		}
+		obs.OnEvent(e)
+	})
+	return mo
+}
+
+// withTenant runs fn against t: on a database opened from t.DSN, and on a
+// connection whose search_path is t.Schema. search_path holds the tenant
+// schema only, so a missing schema fails instead of writing to public.
+func withTenant(db *gorm.DB, t Tenant, open func(dsn string) (*gorm.DB, error), fn func(tdb *gorm.DB) error) error {
+	if t.DSN != "" {
+		tdb, err := open(t.DSN)
+		if err != nil {
+			return fmt.Errorf("connect: %w", err)
+		}
+		if sqlDB, err := tdb.DB(); err == nil {
+			defer sqlDB.Close()
+		}
+		db = tdb.WithContext(dbContext(db))
+	}
+	if t.Schema == "" {
+		return fn(db)
+	}
+	if !strings.EqualFold(db.Dialector.Name(), "postgres") {
+		return fmt.Errorf("tenant schemas need postgres, not %s; give the tenant a DSN instead", db.Dialector.Name())
+	}
+	return withConn(db, func(conn *gorm.DB) (err error) {
+		if err := conn.Exec("SET search_path TO " + quotePostgresIdent(t.Schema)).Error; err != nil {
+			return fmt.Errorf("set search_path: %w", err)
+		}
+		defer func() {
+			// the connection goes back to the pool even if the run was cancelled
+			reset := uncancelled(conn)
+			if rerr := reset.Exec("RESET search_path").Error; rerr != nil && err == nil {
+				err = fmt.Errorf("reset search_path: %w", rerr)
+			}
+		}()
+		return fn(conn)
+	})
+}
@@ -0,0 +1,146 @@
+package driftflow
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// unconnectedDB returns a postgres handle that never reaches a server; the
+// tenant runner only needs its dialect and context.
+func unconnectedDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=x dbname=x sslmode=disable"), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db
+}
+
+func dsnTenants(names ...string) TenantList {
+	l := make(TenantList, 0, len(names))
+	for _, n := range names {
+		l = append(l, Tenant{Name: n, DSN: "dsn-" + n})
+	}
+	return l
+}
+
+func tenantOptions(t *testing.T, src TenantSource) TenantOptions {
+	return TenantOptions{
+		Source: src,
+		Open:   func(string) (*gorm.DB, error) { return unconnectedDB(t), nil },
+	}
+}
+
+func TestNormalizeTenants(t *testing.T) {
+	tenants := TenantList{{Schema: "acme"}, {Name: "globex", DSN: "x"}}
+	if err := normalizeTenants(tenants); err != nil {
+		t.Fatalf("normalizeTenants: %v", err)
+	}
+	if tenants[0].Name != "acme" {
+		t.Fatalf("expected tenant named after schema, got %+v", tenants[0])
+	}
+	for _, bad := range []TenantList{
+		{{}},
+		{{Name: "acme"}},
+		{{Schema: "acme"}, {Name: "acme", DSN: "x"}},
+	} {
+		if err := normalizeTenants(bad); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestRunTenantsContinueOnError(t *testing.T) {
+	opts := tenantOptions(t, dsnTenants("a", "b", "c"))
+	opts.OnFailure = TenantContinueOnError
+	report, err := runTenants(unconnectedDB(t), opts, func(_ *gorm.DB, mo MigrateOptions, res *TenantResult) error {
+		if mo.tenant == "b" {
+			return errors.New("boom")
+		}
+		mo.observer().OnEvent(Event{Kind: EventMigrationApplied, Version: "001_" + mo.tenant})
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "tenant b: boom") {
+		t.Fatalf("expected tenant b failure, got %v", err)
+	}
+	var got []string
+	for _, r := range report.Results {
+		got = append(got, r.Tenant.Name+"="+string(r.Status)+strings.Join(r.Applied, ","))
+	}
+	if strings.Join(got, " ") != "a=ok001_a b=failed c=ok001_c" {
+		t.Fatalf("unexpected report: %v", got)
+	}
+	if report.Results[1].Error != "boom" {
+		t.Fatalf("expected error text in report, got %q", report.Results[1].Error)
+	}
+}
+
+func TestRunTenantsStopOnError(t *testing.T) {
+	opts := tenantOptions(t, dsnTenants("a", "b", "c"))
+	report, err := runTenants(unconnectedDB(t), opts, func(_ *gorm.DB, mo MigrateOptions, _ *TenantResult) error {
+		if mo.tenant == "a" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for i, want := range []TenantRunStatus{TenantFailed, TenantSkipped, TenantSkipped} {
+		if report.Results[i].Status != want {
+			t.Fatalf("tenant %d: expected %s, got %s", i, want, report.Results[i].Status)
+		}
+	}
+}
+
+func TestRunTenantsParallelism(t *testing.T) {
+	opts := tenantOptions(t, dsnTenants("a", "b", "c", "d", "e", "f"))
+	opts.Parallelism = 2
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	_, err := runTenants(unconnectedDB(t), opts, func(_ *gorm.DB, mo MigrateOptions, _ *TenantResult) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		seen[mo.tenant] = true
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runTenants: %v", err)
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("expected at most 2 tenants at once, got %d", peak.Load())
+	}
+	if len(seen) != 6 {
+		t.Fatalf("expected 6 tenants to run, got %v", seen)
+	}
+}
+
+func TestTenantSchemaNeedsPostgres(t *testing.T) {
+	db := unconnectedDB(t)
+	db.Dialector = mysqlNamed{db.Dialector}
+	err := withTenant(db, Tenant{Name: "acme", Schema: "acme"}, nil, func(*gorm.DB) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "need postgres") {
+		t.Fatalf("expected postgres-only error, got %v", err)
+	}
+}
+
+type mysqlNamed struct{ gorm.Dialector }
+
+func (mysqlNamed) Name() string { return "mysql" }
@@ -0,0 +1,119 @@
+package driftflow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// sessionTimeoutSQL returns the statements that apply the lock and statement
+// timeouts of opts to a session on dialect, and those that restore the server
+// defaults afterwards. Timeouts the dialect cannot set per session are left
+// to statementContext.
+func sessionTimeoutSQL(dialect string, opts MigrateOptions) (set, reset []string) {
+	switch strings.ToLower(dialect) {
+	case "postgres":
+		if opts.StatementTimeout > 0 {
+			set = append(set, fmt.Sprintf("SET statement_timeout = %d", opts.StatementTimeout.Milliseconds()))
+			reset = append(reset, "RESET statement_timeout")
+		}
+		if opts.LockTimeout > 0 {
+			set = append(set, fmt.Sprintf("SET lock_timeout = %d", opts.LockTimeout.Milliseconds()))
+			reset = append(reset, "RESET lock_timeout")
+		}
+	case "mysql":
+		if opts.LockTimeout > 0 {
+			// row locks and metadata locks (ALTER TABLE) are bounded separately
+			seconds := int(math.Ceil(opts.LockTimeout.Seconds()))
+			set = append(set,
+				fmt.Sprintf("SET SESSION innodb_lock_wait_timeout = %d", seconds),
+				fmt.Sprintf("SET SESSION lock_wait_timeout = %d", seconds),
+			)
+			reset = append(reset,
+				"SET SESSION innodb_lock_wait_timeout = DEFAULT",
+				"SET SESSION lock_wait_timeout = DEFAULT",
+			)
+		}
+	case "sqlserver":
+		if opts.LockTimeout > 0 {
+			set = append(set, fmt.Sprintf("SET LOCK_TIMEOUT %d", opts.LockTimeout.Milliseconds()))
+			reset = append(reset, "SET LOCK_TIMEOUT -1")
+		}
+	}
+	return set, reset
+}
+
+// withSessionTimeouts runs fn on a single connection with the timeouts of
+// opts applied and restores the session defaults before the connection goes
+// back to the pool. Without timeouts fn receives db unchanged.
+func withSessionTimeouts(db *gorm.DB, opts MigrateOptions, fn func(conn *gorm.DB) error) error {
+	set, reset := sessionTimeoutSQL(db.Dialector.Name(), opts)
+	if len(set) == 0 {
+		return fn(db)
+	}
+	return withConn(db, func(conn *gorm.DB) (err error) {
+		defer func() {
+			// the connection goes back to the pool even if the run was cancelled
+			restore := uncancelled(conn)
+			for _, stmt := range reset {
+				if rerr := restore.Exec(stmt).Error; rerr != nil && err == nil {
+					err = fmt.Errorf("restore session timeouts: %w", rerr)
+				}
+			}
+		}()
+		for _, stmt := range set {
+			if err := conn.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("set session timeouts: %w", err)
+			}
+		}
+		return fn(conn)
+	})
+}
+
+// statementContext bounds a single statement by opts.StatementTimeout on
+// dialects without a session-level statement timeout. The returned cancel
+// function must always be called.
+func statementContext(db *gorm.DB, opts MigrateOptions) (*gorm.DB, context.CancelFunc) {
+	if opts.StatementTimeout <= 0 || strings.EqualFold(db.Dialector.Name(), "postgres") {
+		return db, func() {}
+	}
+	ctx, cancel := context.WithTimeout(dbContext(db), opts.StatementTimeout)
+	return db.WithContext(ctx), cancel
+}
+
+// dbContext returns the context bound to db, or context.Background.
+func dbContext(db *gorm.DB) context.Context {
+	if db.Statement != nil && db.Statement.Context != nil {
+		return db.Statement.Context
+	}
+	return context.Background()
+}
+
+// withConn runs fn on a single connection. If db is already pinned to one,
+// as inside a tenant run, fn gets db itself: gorm cannot pin a connection
+// twice.
+func withConn(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if db.Statement != nil {
+		if _, ok := db.Statement.ConnPool.(*sql.Conn); ok {
+			return fn(db)
+		}
+	}
+	return db.Connection(fn)
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
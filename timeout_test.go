@@ -0,0 +1,52 @@
+package driftflow
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSessionTimeoutSQL(t *testing.T) {
+	opts := MigrateOptions{StatementTimeout: 30 * time.Second, LockTimeout: 1500 * time.Millisecond}
+	cases := []struct {
+		dialect    string
+		set, reset []string
+	}{
+		{
+			dialect: "postgres",
+			set:     []string{"SET statement_timeout = 30000", "SET lock_timeout = 1500"},
+			reset:   []string{"RESET statement_timeout", "RESET lock_timeout"},
+		},
+		{
+			dialect: "mysql",
+			set:     []string{"SET SESSION innodb_lock_wait_timeout = 2", "SET SESSION lock_wait_timeout = 2"},
+			reset:   []string{"SET SESSION innodb_lock_wait_timeout = DEFAULT", "SET SESSION lock_wait_timeout = DEFAULT"},
+		},
+		{
+			dialect: "sqlserver",
+			set:     []string{"SET LOCK_TIMEOUT 1500"},
+			reset:   []string{"SET LOCK_TIMEOUT -1"},
+		},
+		{dialect: "sqlite"},
+	}
+	for _, tc := range cases {
+		set, reset := sessionTimeoutSQL(tc.dialect, opts)
+		if !reflect.DeepEqual(set, tc.set) || !reflect.DeepEqual(reset, tc.reset) {
+			t.Errorf("%s: got set=%q reset=%q", tc.dialect, set, reset)
+		}
+	}
+
+	if set, reset := sessionTimeoutSQL("postgres", MigrateOptions{}); set != nil || reset != nil {
+		t.Errorf("expected no statements without timeouts, got %q %q", set, reset)
+	}
+}
+
+func TestSleepContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
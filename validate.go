@@ -2,21 +2,34 @@ package driftflow
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
 	"regexp"
 	"strings"
-
-	"github.com/misaelcrespo30/DriftFlow/config"
 )
 
-// Validate checks migration files for common issues such as duplicated names
-// or invalid migration sections.
+// Validate checks migration files for common issues such as duplicated names,
+// invalid migration sections or an empty Down section in a migration not
+// marked Irreversible.
 func Validate(dir string) error {
-	if err := config.ValidateDir(dir); err != nil {
+	return validateSource(dirSource(dir))
+}
+
+// ValidateFS is Validate for migrations at the root of fsys, such as an
+// embed.FS narrowed with fs.Sub. It also verifies manifest.lock.json against
+// the embedded files, which Up and friends would otherwise refuse to run.
+func ValidateFS(fsys fs.FS) error {
+	src := fsSource(fsys)
+	if err := validateSource(src); err != nil {
+		return err
+	}
+	return ensureManifestIntegrity(src, ObserverFunc(notify))
+}
+
+func validateSource(src migrationSource) error {
+	if err := src.validate(); err != nil {
 		return err
 	}
-	entries, err := os.ReadDir(dir)
+	entries, err := src.readDir()
 	if err != nil {
 		return err
 	}
@@ -24,13 +37,27 @@ func Validate(dir string) error {
 	seen := make(map[string]struct{})
 	duplicates := []string{}
 	missingDown := []string{}
+	emptyDown := []string{}
 	namingIssues := []string{}
 
 	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") || isCallbackFile(e.Name()) {
 			continue
 		}
 		base := strings.TrimSuffix(e.Name(), ".sql")
+		if isRepeatableFile(base) {
+			body, _, err := readRepeatable(src, src.path(e.Name()), ChecksumRaw)
+			if err != nil || strings.TrimPrefix(base, repeatablePrefix) == "" {
+				missingDown = append(missingDown, base)
+				continue
+			}
+			if _, err := splitSQLStatements(body, ""); err != nil {
+				missingDown = append(missingDown, base)
+				continue
+			}
+			namingIssues = append(namingIssues, checkNamingConventions(body)...)
+			continue
+		}
 		ver := base
 		if idx := strings.Index(base, "_"); idx != -1 {
 			ver = base[:idx]
@@ -40,14 +67,20 @@ func Validate(dir string) error {
 			continue
 		}
 		seen[ver] = struct{}{}
-		upSQL, _, err := readMigrationSections(filepath.Join(dir, base+".sql"))
+		script, _, err := readMigrationScript(src, src.path(e.Name()), ChecksumRaw)
 		if err != nil {
 			missingDown = append(missingDown, base)
 			continue
 		}
-		namingIssues = append(namingIssues, checkNamingConventions(upSQL)...)
+		for _, sel := range script.selections() {
+			if script.irreversible(sel[0], sel[1]) && !script.Irreversible {
+				emptyDown = append(emptyDown, base)
+				break
+			}
+		}
+		namingIssues = append(namingIssues, checkNamingConventions(script.Up)...)
 	}
-	if len(duplicates) > 0 || len(missingDown) > 0 || len(namingIssues) > 0 {
+	if len(duplicates) > 0 || len(missingDown) > 0 || len(emptyDown) > 0 || len(namingIssues) > 0 {
 		var sb strings.Builder
 		if len(duplicates) > 0 {
 			sb.WriteString("duplicate migrations: ")
@@ -60,6 +93,13 @@ func Validate(dir string) error {
 			sb.WriteString("invalid migration files: ")
 			sb.WriteString(strings.Join(missingDown, ", "))
 		}
+		if len(emptyDown) > 0 {
+			if sb.Len() > 0 {
+				sb.WriteString("; ")
+			}
+			sb.WriteString("empty Down sections (mark them " + migrationIrreversibleMarker + " if intended): ")
+			sb.WriteString(strings.Join(emptyDown, ", "))
+		}
 		if len(namingIssues) > 0 {
 			if sb.Len() > 0 {
 				sb.WriteString("; ")
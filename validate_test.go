@@ -3,6 +3,7 @@ package driftflow
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -45,3 +46,39 @@ func TestValidateNamingConvention(t *testing.T) {
 		t.Fatalf("expected naming convention error")
 	}
 }
+
+func TestValidateEmptyDown(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "004_backfill", "UPDATE users SET name = 'x';", "")
+
+	if err := Validate(dir); err == nil || !strings.Contains(err.Error(), "empty Down sections") {
+		t.Fatalf("expected empty down error, got %v", err)
+	}
+
+	content := migrationIrreversibleMarker + "\n" + formatMigrationFile("UPDATE users SET name = 'x';", "")
+	if err := os.WriteFile(filepath.Join(dir, "004_backfill.sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(dir); err != nil {
+		t.Fatalf("expected irreversible migration to validate, got %v", err)
+	}
+}
+
+func TestValidateDialectFilteredDown(t *testing.T) {
+	dir := t.TempDir()
+	content := "-- +migrate Up\nCREATE INDEX idx_users ON users (id);\n\n-- +migrate Down dialect:postgres\nDROP INDEX idx_users;\n"
+	if err := os.WriteFile(filepath.Join(dir, "004_index.sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(dir); err == nil || !strings.Contains(err.Error(), "004_index") {
+		t.Fatalf("expected the Down missing on other dialects to be reported, got %v", err)
+	}
+
+	content = "-- +migrate Up dialect:postgres\nCREATE INDEX idx_users ON users (id);\n\n-- +migrate Down dialect:postgres\nDROP INDEX idx_users;\n"
+	if err := os.WriteFile(filepath.Join(dir, "004_index.sql"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(dir); err != nil {
+		t.Fatalf("expected matching qualifiers to validate, got %v", err)
+	}
+}